@@ -0,0 +1,95 @@
+package battery
+
+import (
+	"fmt"
+	"os/exec"
+	"sort"
+
+	"github.com/jameswelchman/clark/logging"
+)
+
+// Level is a low battery threshold. A level fires once when the
+// combined charge falls below Percent while discharging.
+type Level struct {
+	Percent float64
+
+	// Color is used while the level is active. Urgent also
+	// sets the urgent flag so i3bar draws attention to it.
+	Color  string
+	Urgent bool
+
+	// Hook is run with "sh -c" when the level fires, for example
+	//     notify-send -u critical "Battery low"
+	//     systemctl suspend
+	// An empty Hook runs nothing.
+	Hook string
+}
+
+// alerts tracks which levels have fired.
+type alerts struct {
+	levels     []Level
+	fired      []bool
+	hysteresis float64
+}
+
+func newAlerts(c *Config) *alerts {
+	// Lowest first, so the first fired level is the most severe
+	levels := append([]Level(nil), c.Levels...)
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Percent < levels[j].Percent
+	})
+
+	return &alerts{
+		levels:     levels,
+		fired:      make([]bool, len(levels)),
+		hysteresis: c.Hysteresis,
+	}
+}
+
+// Check fires any level crossed since the last call and clears
+// levels we have recovered from.
+func (a *alerts) Check(percent float64, discharging bool) {
+	for i, l := range a.levels {
+		if !discharging || percent >= l.Percent+a.hysteresis {
+			a.fired[i] = false
+			continue
+		}
+
+		if percent < l.Percent && !a.fired[i] {
+			a.fired[i] = true
+			runHook(l.Hook)
+		}
+	}
+}
+
+// Active returns the most severe level which has fired, or nil.
+func (a *alerts) Active() *Level {
+	for i := range a.levels {
+		if a.fired[i] {
+			return &a.levels[i]
+		}
+	}
+	return nil
+}
+
+// runHook starts the hook without waiting for it to finish.
+// Failures are logged with logging.Error.
+func runHook(hook string) {
+	if hook == "" {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", hook)
+	err := cmd.Start()
+	if err != nil {
+		logging.Error("battery hook failed", err)
+		return
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logging.Error(fmt.Sprintf("battery hook %q failed", hook), err)
+		}
+	}()
+}
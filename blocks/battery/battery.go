@@ -2,57 +2,322 @@ package battery
 
 import (
 	"fmt"
+	"os"
 	"time"
 
+	"github.com/jameswelchman/clark/blocks"
 	"github.com/jameswelchman/clark/colors"
+	"github.com/jameswelchman/clark/logging"
 	"github.com/jameswelchman/clark/pkg/bat"
+	"github.com/jameswelchman/clark/pkg/uevent"
 	"github.com/jameswelchman/clark/protocol"
 )
 
+const (
+	// With uevents we redraw as soon as anything changes, so we
+	// only poll to keep the charge and time remaining fresh.
+	slowPoll = 30 * time.Second
+	fastPoll = time.Second
+
+	// How long a failed threshold write stays on the bar
+	errorTime = 5 * time.Second
+)
+
+// Display modes, right click cycles through them in this order
+const (
+	modeTime = iota
+	modePower
+	modeHealth
+	numModes
+)
+
+// Config holds the settings of the battery block.
+type Config struct {
+	Levels []Level
+
+	// Hysteresis is how far the charge must rise above a level, in
+	// percent, before that level may fire again. Starting to charge
+	// also clears every level.
+	Hysteresis float64
+
+	// Presets are the charge control thresholds which middle click
+	// and scrolling cycle through, on batteries which support them.
+	Presets []bat.Threshold
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	Levels: []Level{
+		{Percent: 40, Color: colors.Yellow},
+		{Percent: 10, Color: colors.Red, Urgent: true,
+			Hook: `notify-send -u critical "Battery low"`},
+	},
+	Hysteresis: 2,
+	Presets: []bat.Threshold{
+		{Start: 55, End: 60},
+		{Start: 75, End: 80},
+		{Start: 0, End: 100},
+	},
+}
+
 type runInfo struct {
-	color         string
-	chargePercent float64
-	status        string
+	conf      *Config
+	alerts    *alerts
+	color     string
+	mode      int
+	status    string
+	reading   *bat.Reading
+	info      *bat.Info
+	pluggedIn bool
+
+	// threshold is nil when the batteries don't support it.
+	// A failed write is shown until thresholdErrUntil.
+	threshold         *bat.Threshold
+	thresholdErr      error
+	thresholdErrUntil time.Time
+
+	// estimator is used when the battery doesn't report a rate
+	estimator bat.Estimator
+
+	// selected is zero for all batteries combined,
+	// otherwise it is one more than the index into batteries
+	selected  int
+	batteries []*bat.Battery
 }
 
 func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
 	block := protocol.Block(*defaultBlock)
+	chargePercent := r.reading.Percentage()
+
+	if r.thresholdErr != nil && time.Now().Before(r.thresholdErrUntil) {
+		block.FullText = r.thresholdErr.Error()
+		block.ShortText = "threshold error"
+		block.Color = colors.Red
+		return &block
+	}
 
 	// Color
 	block.Color = r.color
 	if r.status == "Discharging" {
 		block.Color = colors.White
+	}
+	if level := r.alerts.Active(); level != nil {
+		block.Color = level.Color
+		block.Urgent = level.Urgent
+	}
+
+	// Text
+	block.FullText = fmt.Sprintf("%s %.0f%%", r.StatusText(), chargePercent)
+	block.ShortText = fmt.Sprintf("%.0f%%", chargePercent)
 
-		if r.chargePercent < 40 {
-			block.Color = colors.Yellow
+	switch r.mode {
+	case modeTime:
+		if remaining := r.Remaining(); remaining > 0 {
+			block.FullText += " " + formatDuration(remaining)
+		}
+	case modePower:
+		if r.reading.PowerNow > 0 {
+			block.FullText += fmt.Sprintf(" %.1fW", r.reading.PowerNow/1e6)
+		} else {
+			block.FullText += " --W"
 		}
+	case modeHealth:
+		if health := r.info.Health(r.reading); health > 0 {
+			block.FullText += fmt.Sprintf(" health %.0f%%", health)
+		}
+		if r.info.CycleCount > 0 {
+			block.FullText += fmt.Sprintf(" %d cycles", r.info.CycleCount)
+		}
+	}
 
-		if r.chargePercent < 10 {
-			block.Color = colors.Red
+	if r.threshold != nil {
+		if r.threshold.Start > 0 {
+			block.FullText += fmt.Sprintf(" [%d-%d%%]", r.threshold.Start, r.threshold.End)
+		} else {
+			block.FullText += fmt.Sprintf(" [%d%%]", r.threshold.End)
 		}
 	}
 
-	// Text
-	block.FullText = fmt.Sprintf("%s %.0f%%", r.status, r.chargePercent)
+	if r.selected > 0 {
+		block.FullText = r.batteries[r.selected-1].Name + " " + block.FullText
+	}
 	return &block
 }
 
+// StatusText turns the status file into something readable.
+// Many laptops report "Unknown" when plugged in but not charging,
+// so we check the adapters rather than showing that.
+func (r *runInfo) StatusText() string {
+	switch r.status {
+	case "Charging", "Discharging", "Full", "Not charging":
+		return r.status
+	}
+
+	if r.pluggedIn {
+		return "Plugged in"
+	}
+	return r.status
+}
+
+// Remaining returns the time until empty or full. We prefer the rate
+// reported by the hardware and fall back on our own estimate.
+func (r *runInfo) Remaining() time.Duration {
+	var remaining time.Duration
+	switch r.status {
+	case "Charging":
+		remaining = r.reading.TimeToFull()
+	case "Discharging":
+		remaining = r.reading.TimeToEmpty()
+	default:
+		return 0
+	}
+
+	if remaining == 0 {
+		remaining = r.estimator.Remaining(r.reading.Percentage())
+	}
+
+	return remaining
+}
+
+// formatDuration formats like 2h14m, dropping the hours when zero.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h == 0 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", h, m)
+}
+
 func (r *runInfo) Update() error {
 	var err error
 
+	r.batteries, err = bat.GetBatteries()
+	if err != nil {
+		return fmt.Errorf("couldn't find batteries :: %v", err)
+	}
+
+	// A battery may have been removed
+	if r.selected > len(r.batteries) {
+		r.selected = 0
+		r.estimator = bat.Estimator{}
+	}
+
+	// Alerts always follow the combined charge
+	err = r.updateAll()
+	if err != nil {
+		return err
+	}
+	r.alerts.Check(r.reading.Percentage(), r.status == "Discharging")
+
+	if r.selected > 0 {
+		err = r.updateOne(r.batteries[r.selected-1])
+		if err != nil {
+			return err
+		}
+	}
+
+	r.pluggedIn, err = bat.GetPluggedIn()
+	if err != nil {
+		return fmt.Errorf("couldn't get adapters :: %v", err)
+	}
+
+	r.estimator.Add(r.reading.Percentage(), r.status == "Charging", time.Now())
+	return nil
+}
+
+func (r *runInfo) updateAll() error {
+	var err error
+
 	r.status, err = bat.GetStatus()
 	if err != nil {
 		return fmt.Errorf("couldn't get battery status :: %v", err)
 	}
 
-	r.chargePercent, err = bat.GetChargePercentage()
+	r.reading, err = bat.GetReading()
 	if err != nil {
 		return fmt.Errorf("couldn't get battery charge :: %v", err)
 	}
 
+	r.info, err = bat.GetInfo()
+	if err != nil {
+		return fmt.Errorf("couldn't get battery info :: %v", err)
+	}
+
+	r.threshold, err = bat.GetThreshold()
+	if err == bat.ErrNoThreshold {
+		r.threshold = nil
+	} else if err != nil {
+		return fmt.Errorf("couldn't get charge threshold :: %v", err)
+	}
+
 	return nil
 }
 
+func (r *runInfo) updateOne(b *bat.Battery) error {
+	var err error
+
+	r.status, err = b.Status()
+	if err != nil {
+		return fmt.Errorf("couldn't get %s status :: %v", b.Name, err)
+	}
+
+	r.reading, err = b.Read()
+	if err != nil {
+		return fmt.Errorf("couldn't get %s charge :: %v", b.Name, err)
+	}
+
+	r.info, err = b.Info()
+	if err != nil {
+		return fmt.Errorf("couldn't get %s info :: %v", b.Name, err)
+	}
+
+	return nil
+}
+
+// CycleThreshold writes the next (step 1) or previous (step -1)
+// preset. Failures are kept in r.thresholdErr for display.
+func (r *runInfo) CycleThreshold(step int) error {
+	presets := r.conf.Presets
+	if r.threshold == nil || len(presets) == 0 {
+		return nil
+	}
+
+	next := 0
+	for i, p := range presets {
+		if p.End == r.threshold.End {
+			next = (i + step + len(presets)) % len(presets)
+			break
+		}
+	}
+
+	err := bat.SetThresholds(presets[next])
+	if os.IsPermission(err) {
+		err = fmt.Errorf("can't set threshold :: permission denied")
+	} else if err != nil {
+		err = fmt.Errorf("can't set threshold :: %v", err)
+	}
+
+	r.thresholdErr = err
+	r.thresholdErrUntil = time.Now().Add(errorTime)
+
+	return r.Update()
+}
+
+// Click cycles through each battery and back to the combined
+// reading. With a single battery we toggle the color instead.
+func (r *runInfo) Click() error {
+	if len(r.batteries) < 2 {
+		r.ToggleColor()
+		return nil
+	}
+
+	r.selected = (r.selected + 1) % (len(r.batteries) + 1)
+	r.estimator = bat.Estimator{}
+	return r.Update()
+}
+
 func (r *runInfo) ToggleColor() {
 	if r.color == colors.Grey {
 		r.color = colors.White
@@ -62,15 +327,56 @@ func (r *runInfo) ToggleColor() {
 }
 
 func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
-	ticker := time.NewTicker(time.Second)
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	poll := fastPoll
+	watcher, err := uevent.Watch("power_supply")
+	var events <-chan *uevent.Event
+	if err != nil {
+		logging.Error("couldn't watch power_supply, polling", err)
+	} else {
+		defer watcher.Close()
+		events = watcher.Events
+		poll = slowPoll
+	}
+
+	// Desktops have no battery at all
+	_, err = bat.GetBatteries()
+	if err == bat.ErrNoBattery {
+		if events == nil {
+			return blocks.Hide(defaultBlock, in, out)
+		}
+		waitForBattery(defaultBlock, in, out, events)
+	}
+
+	ticker := time.NewTicker(poll)
 	defer ticker.Stop()
 
 	run := &runInfo{
-		color: colors.Grey,
+		conf:   &c,
+		alerts: newAlerts(&c),
+		color:  colors.Grey,
+	}
+
+	err = run.Update()
+	if err != nil {
+		return err
 	}
+	out <- run.BuildBlock(defaultBlock)
+
+	// The next poll may be slowPoll away, so a threshold error
+	// is cleared by its own timer
+	var clearErr <-chan time.Time
 
 	for {
 		select {
+		case <-clearErr:
+			clearErr = nil
+			out <- run.BuildBlock(defaultBlock)
+
 		case <-ticker.C:
 			err := run.Update()
 			if err != nil {
@@ -80,14 +386,76 @@ func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *pr
 			block := run.BuildBlock(defaultBlock)
 			out <- block
 
+		case _, ok := <-events:
+			if !ok {
+				// The socket failed, go back to polling
+				events = nil
+				ticker.Reset(fastPoll)
+				continue
+			}
+
+			err := run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+
 		case click := <-in:
-			if click.Button != 1 {
+			switch click.Button {
+			case 1:
+				err := run.Click()
+				if err != nil {
+					return err
+				}
+			case 3:
+				run.mode = (run.mode + 1) % numModes
+			case 2, 4, 5:
+				step := 1
+				if click.Button == 5 {
+					step = -1
+				}
+
+				err := run.CycleThreshold(step)
+				if err != nil {
+					return err
+				}
+				if run.thresholdErr != nil {
+					clearErr = time.After(errorTime)
+				}
+			default:
 				continue
 			}
 
-			run.ToggleColor()
 			block := run.BuildBlock(defaultBlock)
 			out <- block
 		}
 	}
 }
+
+// waitForBattery hides the block until a battery is added.
+// If the uevent socket fails we stay hidden for good.
+func waitForBattery(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block, events <-chan *uevent.Event) {
+	out <- blocks.HiddenBlock(defaultBlock)
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				blocks.Hide(defaultBlock, in, out)
+				return
+			}
+
+			if event.Action != "add" && event.Action != uevent.Resync {
+				continue
+			}
+
+			if _, err := bat.GetBatteries(); err == nil {
+				return
+			}
+		case <-in:
+			continue
+		}
+	}
+}
@@ -1,5 +1,17 @@
 /*
 battery implements a battery monitor.
 It displays charging/discharging and the current charge as a percentage.
+All batteries are combined, left click shows each battery in turn.
+Right click switches between the time remaining, the power draw and
+the health of the battery as a percentage of its design capacity.
+The block hides itself on machines without a battery.
+Power supply uevents redraw the block as soon as a charger is plugged
+in or removed, so sysfs is only polled every thirty seconds.
+
+Batteries with charge_control_end_threshold show the active threshold.
+Middle click and scrolling cycle through the presets in Config.
+
+Low battery levels are set in Config. Each level has its own color,
+may set the urgent flag and may run a hook command, once per crossing.
 */
 package battery
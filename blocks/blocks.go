@@ -24,3 +24,27 @@ type Block struct {
 	// Each package must implement it's own version.
 	Run RunFunc
 }
+
+// HiddenBlock returns a copy of defaultBlock with no text,
+// which i3bar will not draw.
+func HiddenBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+	block.FullText = ""
+	block.MinWidth = 0
+	block.MinWidthText = ""
+	block.Separator = false
+	return &block
+}
+
+// Hide sends a HiddenBlock and then discards clicks forever.
+// Run functions may call it when the hardware they monitor does not
+// exist on this machine, rather than returning an error and being
+// restarted every second.
+func Hide(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	out <- HiddenBlock(defaultBlock)
+
+	for range in {
+	}
+
+	return nil
+}
@@ -0,0 +1,185 @@
+/*
+cpufreq implements a cpu frequency display on i3bar.
+It shows the average and maximum frequency, the scaling governor and
+the energy performance preference. Scrolling cycles the governor or
+the preference, right click chooses which of the two is cycled.
+*/
+package cpufreq
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jameswelchman/clark/blocks"
+	"github.com/jameswelchman/clark/colors"
+	freqClient "github.com/jameswelchman/clark/pkg/cpufreq"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+const (
+	// A typical line, i3bar keeps the block at least this wide
+	minWidth = "1.83/3.40 GHz [powersave] balance_performance"
+
+	// How many updates a failed write stays on the bar
+	errorTicks = 3
+)
+
+type runInfo struct {
+	color     string
+	scrollEpp bool
+	stats     *freqClient.Stats
+
+	// writeErr holds the last failed write to sysfs
+	writeErr   error
+	writeTicks int
+}
+
+func (r *runInfo) Update() error {
+	var err error
+	r.stats, err = freqClient.GetStats()
+	if err != nil {
+		return fmt.Errorf("couldn't get cpu frequency :: %v", err)
+	}
+
+	if r.writeTicks > 0 {
+		r.writeTicks--
+	}
+
+	return nil
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+	block.MinWidthText = minWidth
+
+	if r.writeErr != nil && r.writeTicks > 0 {
+		block.FullText = r.writeErr.Error()
+		block.Color = colors.Red
+		return &block
+	}
+
+	governor, epp := r.stats.Governor, r.stats.Epp
+	if r.scrollEpp && epp != "" {
+		epp = "[" + epp + "]"
+	} else {
+		governor = "[" + governor + "]"
+	}
+
+	block.FullText = fmt.Sprintf("%.2f/%.2f GHz %s",
+		r.stats.AvgFreq/1e6, r.stats.MaxFreq/1e6, governor)
+	if epp != "" {
+		block.FullText += " " + epp
+	}
+
+	block.Color = r.color
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
+// Scroll writes the next (step 1) or previous (step -1) governor
+// or preference. Failures are kept in r.writeErr for display.
+func (r *runInfo) Scroll(step int) {
+	var err error
+	var name string
+	if r.scrollEpp && r.stats.Epp != "" {
+		name = "preference"
+		err = freqClient.SetEpp(cycle(r.stats.Epps, r.stats.Epp, step))
+	} else {
+		name = "governor"
+		err = freqClient.SetGovernor(cycle(r.stats.Governors, r.stats.Governor, step))
+	}
+
+	if err != nil {
+		if os.IsPermission(err) {
+			err = fmt.Errorf("can't set %s :: permission denied", name)
+		} else {
+			err = fmt.Errorf("can't set %s :: %v", name, err)
+		}
+		r.writeErr = err
+		r.writeTicks = errorTicks
+		return
+	}
+
+	r.writeErr = nil
+	r.writeTicks = 0
+}
+
+// cycle returns the value step places away from current in values.
+func cycle(values []string, current string, step int) string {
+	if len(values) == 0 {
+		return current
+	}
+
+	for i, v := range values {
+		if v == current {
+			i = (i + step + len(values)) % len(values)
+			return values[i]
+		}
+	}
+
+	return values[0]
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	// Virtual machines usually have no cpufreq at all
+	_, err := freqClient.GetStats()
+	if err == freqClient.ErrNoCpufreq {
+		return blocks.Hide(defaultBlock, in, out)
+	}
+
+	run := runInfo{
+		color: colors.Grey,
+	}
+
+	err = run.Update()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		case click := <-in:
+			switch click.Button {
+			case 1:
+				run.ToggleColor()
+			case 3:
+				run.scrollEpp = !run.scrollEpp
+			case 4:
+				run.Scroll(1)
+			case 5:
+				run.Scroll(-1)
+			default:
+				// Don't send an update if nothing changed
+				continue
+			}
+
+			// Pick up whatever we just wrote
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		}
+	}
+}
@@ -0,0 +1,261 @@
+/*
+ipaddr implements an IP address display on i3bar.
+It shows the primary address of the interface carrying the default
+route. Left click cycles through every interface with an address and
+back to following the default route, right click switches between
+IPv4 and IPv6. Middle click runs Config.Command with the address on
+its stdin, by default copying it to the clipboard.
+*/
+package ipaddr
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jameswelchman/clark/colors"
+	"github.com/jameswelchman/clark/logging"
+	"github.com/jameswelchman/clark/pkg/route"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+// Config holds the settings of the ipaddr block.
+type Config struct {
+	// Command is run with sh -c on middle click,
+	// the address is written to its stdin.
+	Command string
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	Command: "xclip -selection clipboard",
+}
+
+type runInfo struct {
+	conf *Config
+
+	// pinned is the interface chosen by clicking,
+	// empty to follow the default route
+	pinned  string
+	ipv6    bool
+	device  string
+	address net.IP
+}
+
+func (r *runInfo) Update() error {
+	r.device = r.pinned
+	if r.device == "" {
+		device, err := route.GetDefaultInterface()
+		if err == route.ErrNoRoute {
+			r.address = nil
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("couldn't find default route :: %v", err)
+		}
+		r.device = device
+	}
+
+	iface, err := net.InterfaceByName(r.device)
+	if err != nil && r.pinned != "" {
+		// A pinned interface which has gone away,
+		// go back to following the default route
+		r.pinned = ""
+		return r.Update()
+	} else if err != nil {
+		// The route's interface went away as we looked it up
+		r.device = ""
+		r.address = nil
+		return nil
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return fmt.Errorf("couldn't get addresses of %s :: %v", r.device, err)
+	}
+
+	r.address = primaryAddress(addrs, r.ipv6)
+	return nil
+}
+
+// primaryAddress picks the first global address of the family,
+// falling back to a link local one.
+func primaryAddress(addrs []net.Addr, ipv6 bool) net.IP {
+	var linkLocal net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || (ipNet.IP.To4() == nil) != ipv6 {
+			continue
+		}
+
+		if ipNet.IP.IsLinkLocalUnicast() {
+			if linkLocal == nil {
+				linkLocal = ipNet.IP
+			}
+			continue
+		}
+
+		return ipNet.IP
+	}
+
+	return linkLocal
+}
+
+// interfaces lists the interfaces which are up with an address,
+// excluding loopback, in index order.
+func interfaces() ([]string, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Index < ifaces[j].Index
+	})
+
+	var names []string
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+
+		addrs, err := iface.Addrs()
+		if err != nil || len(addrs) == 0 {
+			continue
+		}
+
+		names = append(names, iface.Name)
+	}
+
+	return names, nil
+}
+
+// NextInterface pins the interface after the current one. After the
+// last we go back to following the default route.
+func (r *runInfo) NextInterface() error {
+	names, err := interfaces()
+	if err != nil {
+		return fmt.Errorf("couldn't list interfaces :: %v", err)
+	}
+
+	next := ""
+	if r.pinned == "" {
+		if len(names) > 0 {
+			next = names[0]
+		}
+	} else {
+		for i, name := range names {
+			if name == r.pinned && i+1 < len(names) {
+				next = names[i+1]
+			}
+		}
+	}
+
+	r.pinned = next
+	return r.Update()
+}
+
+func (r *runInfo) family() string {
+	if r.ipv6 {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+	block.Color = colors.Grey
+
+	switch {
+	case r.device == "":
+		block.FullText = "No Address"
+		block.Color = colors.Red
+	case r.address == nil:
+		block.FullText = fmt.Sprintf("%s no %s", r.device, r.family())
+	default:
+		block.FullText = fmt.Sprintf("%s %s", r.device, r.address)
+		block.ShortText = r.address.String()
+	}
+
+	// Show that we aren't following the default route
+	if r.pinned != "" {
+		block.FullText = "*" + block.FullText
+	}
+
+	return &block
+}
+
+// RunCommand runs the configured command with the address on stdin.
+func (r *runInfo) RunCommand() {
+	if r.conf.Command == "" || r.address == nil {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", r.conf.Command)
+	cmd.Stdin = strings.NewReader(r.address.String())
+	err := cmd.Start()
+	if err != nil {
+		logging.Error("ipaddr command failed", err)
+		return
+	}
+
+	go func() {
+		err := cmd.Wait()
+		if err != nil {
+			logging.Error(fmt.Sprintf("ipaddr command %q failed", r.conf.Command), err)
+		}
+	}()
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	run := runInfo{
+		conf: &c,
+	}
+
+	err := run.Update()
+	if err != nil {
+		return err
+	}
+	out <- run.BuildBlock(defaultBlock)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			out <- run.BuildBlock(defaultBlock)
+		case click := <-in:
+			switch click.Button {
+			case 1:
+				err = run.NextInterface()
+			case 2:
+				run.RunCommand()
+				continue
+			case 3:
+				run.ipv6 = !run.ipv6
+				err = run.Update()
+			default:
+				// Don't send an update if nothing changed
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			out <- run.BuildBlock(defaultBlock)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+loadavg implements a load average display on i3bar.
+It shows the 1, 5 and 15 minute load averages and running/total tasks.
+The color is chosen relative to the number of cpus.
+*/
+package loadavg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jameswelchman/clark/colors"
+	cpuClient "github.com/jameswelchman/clark/pkg/cpu"
+	loadClient "github.com/jameswelchman/clark/pkg/loadavg"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+const (
+	// Length of load/cpu [1.00 1.00 1.00] [10/1000]
+	textLength = len("load/cpu [1.00 1.00 1.00] [10/1000]")
+
+	// The one minute load per cpu at which we change color
+	warnLoad = 0.7
+	critLoad = 1.0
+)
+
+type runInfo struct {
+	perCpu  bool
+	color   string
+	numCpus int
+	load    *loadClient.Load
+}
+
+func (r *runInfo) Update() error {
+	var err error
+	r.load, err = loadClient.GetLoad()
+	if err != nil {
+		return fmt.Errorf("couldn't get load average :: %v", err)
+	}
+
+	return nil
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+
+	perCpu := r.load.PerCpu(r.numCpus)
+	if r.perCpu {
+		block.FullText = fmt.Sprintf("load/cpu [%.2f %.2f %.2f] [%d/%d]",
+			perCpu.One, perCpu.Five, perCpu.Fifteen,
+			r.load.Running, r.load.Total)
+	} else {
+		block.FullText = fmt.Sprintf("load [%.2f %.2f %.2f] [%d/%d]",
+			r.load.One, r.load.Five, r.load.Fifteen,
+			r.load.Running, r.load.Total)
+	}
+	if padding := textLength - len(block.FullText); padding > 0 {
+		block.FullText += strings.Repeat(" ", padding)
+	}
+
+	block.Color = r.color
+	if perCpu.One >= warnLoad {
+		block.Color = colors.Yellow
+	}
+	if perCpu.One >= critLoad {
+		block.Color = colors.Red
+	}
+
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	client, err := cpuClient.NewClient()
+	if err != nil {
+		return fmt.Errorf("couldn't count cpus :: %v", err)
+	}
+
+	run := runInfo{
+		numCpus: client.NumCpus(),
+		color:   colors.Grey,
+	}
+
+	err = run.Update()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		case click := <-in:
+			if click.Button == 1 {
+				run.ToggleColor()
+			} else if click.Button == 3 {
+				run.perCpu = !run.perCpu
+			} else {
+				// Don't send an update if nothing changed
+				continue
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		}
+	}
+}
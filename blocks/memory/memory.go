@@ -6,47 +6,139 @@ import (
 
 	"github.com/jameswelchman/clark/colors"
 	memClient "github.com/jameswelchman/clark/pkg/memory"
+	"github.com/jameswelchman/clark/pkg/units"
 	"github.com/jameswelchman/clark/protocol"
 )
 
-func update(block *protocol.Block) error {
-	free, total, err := memClient.GetMemory()
+// Display modes, right click cycles through them in this order
+const (
+	modeUsed = iota
+	modePercent
+	modeBreakdown
+	numModes
+)
+
+// Config holds the settings of the memory block.
+type Config struct {
+	// Cgroup reports memory for the cgroup v2 scope clark runs in,
+	// so limits set by systemd-run or toolbox are shown. We fall back
+	// on the whole system when there is no cgroup v2 memory controller.
+	Cgroup bool
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{}
+
+type runInfo struct {
+	conf  *Config
+	color string
+	mode  int
+
+	// label is "Mem" for the system and "Scope" for a cgroup
+	label string
+	used  uint64
+	total uint64
+	anon  uint64
+	cache uint64
+	shmem uint64
+}
+
+func (r *runInfo) Update() error {
+	info, err := memClient.GetMemInfo()
 	if err != nil {
 		return err
 	}
-	used := total - free
-	perc := (used / total) * 100
-	txt := fmt.Sprintf("Mem %.1f GB / %.1f GB [%.2f%%]", used, total, perc)
-	block.FullText = txt
+
+	if r.conf.Cgroup {
+		cgroup, err := memClient.GetCgroup()
+		if err == nil {
+			r.label = "Scope"
+			r.used = cgroup.Current
+			r.total = cgroup.Max
+			if r.total == 0 || r.total > info.MemTotal {
+				r.total = info.MemTotal
+			}
+			r.anon = cgroup.Anon
+			r.cache = cgroup.File - cgroup.Shmem
+			r.shmem = cgroup.Shmem
+			return nil
+		} else if err != memClient.ErrNoCgroup {
+			return err
+		}
+	}
+
+	// Cached includes Shmem, which can't be dropped like cache
+	r.label = "Mem"
+	r.used = info.MemTotal - info.MemAvailable
+	r.total = info.MemTotal
+	r.anon = info.AnonPages
+	r.cache = info.Buffers + info.Cached - info.Shmem
+	r.shmem = info.Shmem
 
 	return nil
 }
 
+func format(bytes uint64) string {
+	return units.IEC.Bytes(float64(bytes))
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+
+	perc := float64(r.used) / float64(r.total) * 100
+	switch r.mode {
+	case modeUsed:
+		block.FullText = fmt.Sprintf("%s %s / %s [%.2f%%]",
+			r.label, format(r.used), format(r.total), perc)
+	case modePercent:
+		block.FullText = fmt.Sprintf("%s %.2f%%", r.label, perc)
+	case modeBreakdown:
+		block.FullText = fmt.Sprintf("%s anon %s cache %s shmem %s",
+			r.label, format(r.anon), format(r.cache), format(r.shmem))
+	}
+	block.ShortText = fmt.Sprintf("%s %.0f%%", r.label, perc)
+
+	block.Color = r.color
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
 func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
-	color := colors.Grey
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	run := runInfo{
+		conf:  &c,
+		color: colors.Grey,
+	}
 
 	throttle := time.After(0)
 	for {
 		select {
 		case <-throttle:
-			block := protocol.Block(*defaultBlock)
-			if err := update(&block); err != nil {
+			if err := run.Update(); err != nil {
 				err = fmt.Errorf("couldn't update memory :: %v", err)
 				return err
 			}
-			block.Color = color
-			out <- &block
+			out <- run.BuildBlock(defaultBlock)
 			throttle = time.After(time.Second)
 
 		case click := <-in:
-			if click.Button != 1 {
-				continue
-			}
-
-			if color == colors.Grey {
-				color = colors.White
+			if click.Button == 1 {
+				run.ToggleColor()
+			} else if click.Button == 3 {
+				run.mode = (run.mode + 1) % numModes
 			} else {
-				color = colors.Grey
+				continue
 			}
 
 			throttle = time.After(0)
@@ -0,0 +1,139 @@
+/*
+psi implements a pressure stall information display on i3bar.
+It shows the avg10 share of time tasks were stalled on the cpu,
+memory and io. The block hides itself on kernels without psi.
+*/
+package psi
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jameswelchman/clark/blocks"
+	"github.com/jameswelchman/clark/colors"
+	psiClient "github.com/jameswelchman/clark/pkg/psi"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+// Config holds the settings of the psi block.
+type Config struct {
+	// The some avg10 percentages at which memory and io pressure
+	// turn the block yellow (Warn) and red and urgent (Crit).
+	MemoryWarn float64
+	MemoryCrit float64
+	IoWarn     float64
+	IoCrit     float64
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	MemoryWarn: 10,
+	MemoryCrit: 40,
+	IoWarn:     20,
+	IoCrit:     60,
+}
+
+type runInfo struct {
+	conf   *Config
+	color  string
+	cpu    *psiClient.Pressure
+	memory *psiClient.Pressure
+	io     *psiClient.Pressure
+}
+
+func (r *runInfo) Update() error {
+	var err error
+	r.cpu, err = psiClient.GetPressure(psiClient.Cpu)
+	if err != nil {
+		return fmt.Errorf("couldn't get cpu pressure :: %v", err)
+	}
+
+	r.memory, err = psiClient.GetPressure(psiClient.Memory)
+	if err != nil {
+		return fmt.Errorf("couldn't get memory pressure :: %v", err)
+	}
+
+	r.io, err = psiClient.GetPressure(psiClient.Io)
+	if err != nil {
+		return fmt.Errorf("couldn't get io pressure :: %v", err)
+	}
+
+	return nil
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+
+	block.FullText = fmt.Sprintf("psi cpu %.1f mem %.1f/%.1f io %.1f/%.1f",
+		r.cpu.Some.Avg10,
+		r.memory.Some.Avg10, r.memory.Full.Avg10,
+		r.io.Some.Avg10, r.io.Full.Avg10)
+	block.MinWidthText = "psi cpu 10.0 mem 10.0/10.0 io 10.0/10.0"
+
+	memory, io := r.memory.Some.Avg10, r.io.Some.Avg10
+
+	block.Color = r.color
+	if memory >= r.conf.MemoryWarn || io >= r.conf.IoWarn {
+		block.Color = colors.Yellow
+	}
+	if memory >= r.conf.MemoryCrit || io >= r.conf.IoCrit {
+		block.Color = colors.Red
+		block.Urgent = true
+	}
+
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	_, err := psiClient.GetPressure(psiClient.Cpu)
+	if err == psiClient.ErrNotSupported {
+		return blocks.Hide(defaultBlock, in, out)
+	}
+
+	run := runInfo{
+		conf:  &c,
+		color: colors.Grey,
+	}
+
+	err = run.Update()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		case click := <-in:
+			if click.Button != 1 {
+				continue
+			}
+
+			run.ToggleColor()
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		}
+	}
+}
@@ -0,0 +1,190 @@
+/*
+swap implements a swap display on i3bar.
+It shows swap used/total along with the compression ratio of any zram
+devices and the size of the zswap pool. The block turns red and urgent
+when swap usage climbs quickly, and hides itself when there is no swap.
+*/
+package swap
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jameswelchman/clark/blocks"
+	"github.com/jameswelchman/clark/colors"
+	memClient "github.com/jameswelchman/clark/pkg/memory"
+	swapClient "github.com/jameswelchman/clark/pkg/swap"
+	"github.com/jameswelchman/clark/pkg/units"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+// Config holds the settings of the swap block.
+type Config struct {
+	// The block turns urgent when swap used grows by more than
+	// ClimbRate bytes per second, averaged over Window seconds.
+	ClimbRate uint64
+	Window    int
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	ClimbRate: 16 * 1048576,
+	Window:    10,
+}
+
+type runInfo struct {
+	conf  *Config
+	color string
+	info  *memClient.MemInfo
+	zram  []*swapClient.Zram
+	zswap *swapClient.Zswap
+
+	// used is a ring buffer of swap used, one entry per second
+	used []uint64
+	pos  int
+}
+
+func (r *runInfo) Update() error {
+	var err error
+	r.info, err = memClient.GetMemInfo()
+	if err != nil {
+		return fmt.Errorf("couldn't get meminfo :: %v", err)
+	}
+
+	r.zram, err = swapClient.GetZram()
+	if err != nil {
+		return fmt.Errorf("couldn't get zram :: %v", err)
+	}
+
+	r.zswap = swapClient.GetZswap(r.info)
+
+	r.used[r.pos] = r.info.SwapTotal - r.info.SwapFree
+	r.pos = (r.pos + 1) % len(r.used)
+
+	return nil
+}
+
+// Fill sets the whole window to the current swap used,
+// so we don't start off climbing.
+func (r *runInfo) Fill() {
+	for i := range r.used {
+		r.used[i] = r.info.SwapTotal - r.info.SwapFree
+	}
+}
+
+// Climbing reports whether swap used has grown faster than the
+// configured rate over the window. r.pos is the oldest entry.
+func (r *runInfo) Climbing() bool {
+	oldest := r.used[r.pos]
+	newest := r.used[(r.pos+len(r.used)-1)%len(r.used)]
+	if newest <= oldest {
+		return false
+	}
+
+	return (newest-oldest)/uint64(len(r.used)-1) > r.conf.ClimbRate
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+
+	used := r.info.SwapTotal - r.info.SwapFree
+	block.FullText = fmt.Sprintf("Swap %s / %s",
+		units.IEC.Bytes(float64(used)), units.IEC.Bytes(float64(r.info.SwapTotal)))
+	block.ShortText = block.FullText
+
+	for _, z := range r.zram {
+		if z.ComprData == 0 {
+			continue
+		}
+		block.FullText += fmt.Sprintf(" %s %.1fx", z.Name, z.Ratio())
+	}
+
+	if r.zswap != nil && r.zswap.Enabled && r.zswap.PoolSize > 0 {
+		block.FullText += fmt.Sprintf(" zswap %s %.1fx",
+			units.IEC.Bytes(float64(r.zswap.PoolSize)), r.zswap.Ratio())
+	}
+
+	block.Color = r.color
+	if r.Climbing() {
+		block.Color = colors.Red
+		block.Urgent = true
+	}
+
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	window := c.Window
+	if window < 2 {
+		window = 2
+	}
+
+	run := runInfo{
+		conf:  &c,
+		color: colors.Grey,
+		used:  make([]uint64, window),
+	}
+
+	err := run.Update()
+	if err != nil {
+		return err
+	}
+
+	// While there is no swap we stay hidden and keep polling for a swapon
+	hidden := run.info.SwapTotal == 0
+	if hidden {
+		out <- blocks.HiddenBlock(defaultBlock)
+	} else {
+		run.Fill()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			if run.info.SwapTotal == 0 {
+				if !hidden {
+					hidden = true
+					out <- blocks.HiddenBlock(defaultBlock)
+				}
+				continue
+			}
+
+			if hidden {
+				hidden = false
+				run.Fill()
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		case click := <-in:
+			if hidden || click.Button != 1 {
+				continue
+			}
+
+			run.ToggleColor()
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		}
+	}
+}
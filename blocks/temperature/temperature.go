@@ -0,0 +1,155 @@
+/*
+temperature implements a temperature and fan display on i3bar.
+It shows the hottest sensor, or the one named in Config.Sensor, and the
+fastest fan. The block turns yellow and then red and urgent as the
+sensor approaches its critical temperature.
+*/
+package temperature
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jameswelchman/clark/blocks"
+	"github.com/jameswelchman/clark/colors"
+	"github.com/jameswelchman/clark/pkg/hwmon"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+// Config holds the settings of the temperature block.
+type Config struct {
+	// Sensor is the name ("chip/label") or label of the sensor to show.
+	// The hottest sensor is shown when Sensor is empty or not found.
+	Sensor string
+
+	// WarnMargin and CritMargin are degrees below the critical
+	// temperature at which the block turns yellow and red.
+	// Sensors with a max temperature also turn yellow above it.
+	WarnMargin float64
+	CritMargin float64
+
+	// DefaultCrit is used for sensors without a critical temperature.
+	DefaultCrit float64
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	WarnMargin:  20,
+	CritMargin:  5,
+	DefaultCrit: 100,
+}
+
+type runInfo struct {
+	conf   *Config
+	color  string
+	sensor *hwmon.Sensor
+	fan    *hwmon.Fan
+}
+
+func (r *runInfo) Update() error {
+	sensors, err := hwmon.GetSensors()
+	if err != nil {
+		return fmt.Errorf("couldn't get temperatures :: %v", err)
+	}
+
+	r.sensor = hwmon.Find(sensors, r.conf.Sensor)
+	if r.sensor == nil {
+		r.sensor = hwmon.Hottest(sensors)
+	}
+
+	fans, err := hwmon.GetFans()
+	if err != nil {
+		return fmt.Errorf("couldn't get fans :: %v", err)
+	}
+
+	r.fan = nil
+	for _, f := range fans {
+		if r.fan == nil || f.Rpm > r.fan.Rpm {
+			r.fan = f
+		}
+	}
+
+	return nil
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+
+	block.ShortText = fmt.Sprintf("%.0f°C", r.sensor.Temp)
+	block.FullText = fmt.Sprintf("%s %.0f°C", r.sensor.Label, r.sensor.Temp)
+	if r.fan != nil {
+		block.FullText += fmt.Sprintf(" %.0frpm", r.fan.Rpm)
+	}
+
+	crit := r.sensor.Crit
+	if crit <= 0 {
+		crit = r.conf.DefaultCrit
+	}
+
+	block.Color = r.color
+	if r.sensor.Temp >= crit-r.conf.WarnMargin ||
+		(r.sensor.Max > 0 && r.sensor.Temp >= r.sensor.Max) {
+		block.Color = colors.Yellow
+	}
+	if r.sensor.Temp >= crit-r.conf.CritMargin {
+		block.Color = colors.Red
+		block.Urgent = true
+	}
+
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	// Some virtual machines have no sensors at all
+	_, err := hwmon.GetSensors()
+	if err == hwmon.ErrNoSensors {
+		return blocks.Hide(defaultBlock, in, out)
+	}
+
+	run := runInfo{
+		conf:  &c,
+		color: colors.Grey,
+	}
+
+	err = run.Update()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		case click := <-in:
+			if click.Button != 1 {
+				continue
+			}
+
+			run.ToggleColor()
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+top implements a top process display on i3bar.
+It shows the name and pid of the process using the most cpu, or the
+most memory after a right click. Left click cycles through the top five.
+*/
+package top
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jameswelchman/clark/colors"
+	procClient "github.com/jameswelchman/clark/pkg/proc"
+	"github.com/jameswelchman/clark/pkg/units"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+// numTop is how many processes left click cycles through
+const numTop = 5
+
+type runInfo struct {
+	byMem  bool
+	index  int
+	client *procClient.Client
+
+	// processes is the last sample, top is the first numTop of it
+	processes []*procClient.Process
+	top       []*procClient.Process
+}
+
+func (r *runInfo) Update() error {
+	var err error
+	r.processes, err = r.client.Sample()
+	if err != nil {
+		return fmt.Errorf("couldn't sample processes :: %v", err)
+	}
+
+	r.Sort()
+	return nil
+}
+
+// Sort picks the top processes of the last sample by memory or cpu.
+func (r *runInfo) Sort() {
+	if r.byMem {
+		r.top = procClient.Top(r.processes, numTop, procClient.ByRss)
+	} else {
+		r.top = procClient.Top(r.processes, numTop, procClient.ByCpu)
+	}
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+	block.Color = colors.White
+
+	if len(r.top) == 0 {
+		block.FullText = "top none"
+		return &block
+	}
+
+	index := r.index % len(r.top)
+	p := r.top[index]
+
+	label := "top"
+	if index != 0 {
+		label = fmt.Sprintf("top#%d", index+1)
+	}
+
+	if r.byMem {
+		block.FullText = fmt.Sprintf("%s %s[%d] %s",
+			label, p.Name, p.Pid, units.IEC.Bytes(float64(p.Rss)))
+	} else {
+		block.FullText = fmt.Sprintf("%s %s[%d] %.1f%%",
+			label, p.Name, p.Pid, p.Cpu)
+	}
+	block.ShortText = p.Name
+
+	return &block
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	run := runInfo{
+		client: procClient.NewClient(),
+	}
+
+	// The first sample only records cpu times
+	err := run.Update()
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		case click := <-in:
+			if click.Button == 1 {
+				run.index = (run.index + 1) % numTop
+			} else if click.Button == 3 {
+				run.byMem = !run.byMem
+				run.index = 0
+
+				// Sampling again this soon after a tick would show
+				// almost no cpu, so re-sort the last sample instead
+				run.Sort()
+			} else {
+				// Don't send an update if nothing changed
+				continue
+			}
+
+			block := run.BuildBlock(defaultBlock)
+			out <- block
+		}
+	}
+}
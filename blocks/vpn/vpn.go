@@ -0,0 +1,206 @@
+/*
+vpn implements a VPN status display on i3bar.
+It lists the tun, tap and WireGuard interfaces which are up. WireGuard
+interfaces also show the age of their latest handshake and the bytes
+received and sent, when we are allowed to read them. A handshake older
+than Config.StaleHandshake turns the block yellow.
+Interfaces in Config.MustBeUp turn the block red and urgent when they
+are down or missing. With no VPN to show the block hides itself.
+*/
+package vpn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jameswelchman/clark/blocks"
+	"github.com/jameswelchman/clark/colors"
+	"github.com/jameswelchman/clark/logging"
+	"github.com/jameswelchman/clark/pkg/units"
+	vpnClient "github.com/jameswelchman/clark/pkg/vpn"
+	"github.com/jameswelchman/clark/pkg/wireguard"
+	"github.com/jameswelchman/clark/protocol"
+)
+
+// Config holds the settings of the vpn block.
+type Config struct {
+	// MustBeUp names interfaces, such as "wg0", which we want up
+	MustBeUp []string
+
+	// WireGuard rekeys every two minutes while there is traffic,
+	// a handshake older than this means the peer has gone away
+	StaleHandshake time.Duration
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	StaleHandshake: 3 * time.Minute,
+}
+
+type runInfo struct {
+	conf    *Config
+	color   string
+	ifaces  []*vpnClient.Interface
+	devices map[string]*wireguard.Device
+	wg      *wireguard.Client
+	errs    logging.ErrorLog
+}
+
+func (r *runInfo) Update() error {
+	var err error
+	r.ifaces, err = vpnClient.GetInterfaces()
+	if err != nil {
+		return fmt.Errorf("couldn't find vpn interfaces :: %v", err)
+	}
+
+	r.devices = map[string]*wireguard.Device{}
+	for _, iface := range r.ifaces {
+		if iface.Kind != vpnClient.WireGuard || !iface.Up {
+			continue
+		}
+
+		// Without CAP_NET_ADMIN we can still show that it's up,
+		// so log the error once rather than failing
+		device, err := r.wg.GetDevice(iface.Name)
+		r.errs.Log("couldn't read wireguard "+iface.Name, err)
+		if err != nil {
+			continue
+		}
+		r.devices[iface.Name] = device
+	}
+
+	return nil
+}
+
+// missing returns the interfaces in MustBeUp which aren't up.
+func (r *runInfo) missing() []string {
+	var missing []string
+	for _, name := range r.conf.MustBeUp {
+		up := false
+		for _, iface := range r.ifaces {
+			if iface.Name == name && iface.Up {
+				up = true
+			}
+		}
+
+		if !up {
+			missing = append(missing, name)
+		}
+	}
+
+	return missing
+}
+
+// formatAge rounds a handshake age to its largest unit.
+func formatAge(age time.Duration) string {
+	switch {
+	case age < time.Minute:
+		return fmt.Sprintf("%ds", int(age.Seconds()))
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	default:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	}
+}
+
+func (r *runInfo) BuildBlock(defaultBlock *protocol.Block) *protocol.Block {
+	block := protocol.Block(*defaultBlock)
+	block.Color = r.color
+
+	var parts []string
+	for _, iface := range r.ifaces {
+		if !iface.Up {
+			continue
+		}
+
+		device, ok := r.devices[iface.Name]
+		if !ok {
+			parts = append(parts, iface.Name)
+			continue
+		}
+
+		handshake := device.LastHandshake()
+		if handshake.IsZero() {
+			parts = append(parts, iface.Name+" no handshake")
+			block.Color = colors.Yellow
+			continue
+		}
+
+		age := time.Since(handshake)
+		if age > r.conf.StaleHandshake {
+			block.Color = colors.Yellow
+		}
+
+		parts = append(parts, fmt.Sprintf("%s %s down[%s] up[%s]", iface.Name,
+			formatAge(age),
+			units.IEC.Bytes(float64(device.RxBytes())),
+			units.IEC.Bytes(float64(device.TxBytes()))))
+	}
+
+	if missing := r.missing(); len(missing) > 0 {
+		for _, name := range missing {
+			parts = append(parts, name+" down")
+		}
+		block.Color = colors.Red
+		block.Urgent = true
+	}
+
+	if len(parts) == 0 {
+		return blocks.HiddenBlock(defaultBlock)
+	}
+
+	block.FullText = "VPN " + strings.Join(parts, " ")
+	block.ShortText = "VPN"
+	return &block
+}
+
+func (r *runInfo) ToggleColor() {
+	if r.color == colors.Grey {
+		r.color = colors.White
+	} else {
+		r.color = colors.Grey
+	}
+}
+
+func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	run := runInfo{
+		conf:  &c,
+		color: colors.Grey,
+		wg:    wireguard.NewClient(),
+	}
+	defer run.wg.Close()
+
+	err := run.Update()
+	if err != nil {
+		return err
+	}
+	out <- run.BuildBlock(defaultBlock)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err = run.Update()
+			if err != nil {
+				return err
+			}
+
+			out <- run.BuildBlock(defaultBlock)
+		case click := <-in:
+			if click.Button != 1 {
+				continue
+			}
+			run.ToggleColor()
+
+			out <- run.BuildBlock(defaultBlock)
+		}
+	}
+}
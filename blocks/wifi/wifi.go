@@ -1,29 +1,118 @@
 package wifi
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/jameswelchman/clark/colors"
+	"github.com/jameswelchman/clark/logging"
+	"github.com/jameswelchman/clark/pkg/linkstate"
+	"github.com/jameswelchman/clark/pkg/probe"
+	"github.com/jameswelchman/clark/pkg/route"
+	"github.com/jameswelchman/clark/pkg/units"
+	"github.com/jameswelchman/clark/pkg/wireless"
 	"github.com/jameswelchman/clark/protocol"
 
 	"github.com/jameswelchman/clark/blocks/wifi/wifibytes"
 )
 
 const (
-	device     = "wlp2s0"
-	textLength = len("down[9999.99 kbs] up[99.99]")
+	netPath = "/sys/class/net/"
+
+	// The rates are averaged over this many reads, one a second
+	numReads = 10
+
+	// The widest rate, which sets the width of the block
+	widestRate = "999.99 kb/s"
+
+	// How often the data usage is written to disk
+	saveInterval = time.Minute
+
+	// The fraction of the quota at which we turn yellow
+	quotaWarn = 0.9
+
+	// Without internet we probe again after retryFirst,
+	// doubling the wait each time up to retryMax
+	retryFirst = 5 * time.Second
+	retryMax   = 2 * time.Minute
 )
 
+// The displays cycled through by right click
+const (
+	modeLink = iota
+	modeRates
+	modeToday
+	modeMonth
+	numModes
+)
+
+var (
+	rateFormat = units.Format{System: units.Decimal, Precision: 2}
+
+	// From lowest to highest
+	sparks = []rune("▁▂▃▄▅▆▇█")
+)
+
+// Config holds the settings of the wifi block.
+type Config struct {
+	// Interfaces restricts the block to these interfaces. The one
+	// carrying the default route is preferred, otherwise the first
+	// which exists. When empty we follow the default route wherever
+	// it goes, for example from wifi to a USB ethernet dongle.
+	Interfaces []string
+
+	// Probe checks whether we can reach the internet when the link
+	// comes up and when traffic stops, see pkg/probe. ProbeTimeout is how
+	// long we wait for it before deciding we are offline.
+	Probe        probe.Probe
+	ProbeTimeout time.Duration
+
+	// Accounting keeps the bytes moved by each interface per day and
+	// per month in wifibytes.UsagePath(), shown by right click.
+	// Quota is the bytes allowed per month, or per day with
+	// QuotaDaily. We turn yellow near it and red above it,
+	// zero means there is no quota.
+	Accounting bool
+	Quota      uint64
+	QuotaDaily bool
+
+	// Bytes shows the rates in bytes rather than bits a second,
+	// middle click switches between them. Sparkline is how many
+	// of the last rates to draw as a sparkline, zero for none.
+	Bytes     bool
+	Sparkline int
+}
+
+// DefaultConfig is used by Run.
+var DefaultConfig = Config{
+	Probe:        probe.HTTP{URL: "http://clients3.google.com/generate_204"},
+	ProbeTimeout: 20 * time.Second,
+	Accounting:   true,
+}
+
 type runDetails struct {
 	DefaultBlock *protocol.Block
 	ClickChannel <-chan *protocol.Click
 	BlockChannel chan<- *protocol.Block
+	Device       string
 	Down         float64
 	Up           float64
 	Color        string
+	Mode         int
+	Bytes        bool
+	State        linkstate.State
+	Events       <-chan *linkstate.Event
+	conf         *Config
+	client       *wifibytes.Client
+	linkClient   *wireless.Client
+	link         *wireless.Link
+	portal       bool
+	usage        *wifibytes.Usage
+	lastSave     time.Time
+	errs         logging.ErrorLog
 	err          error
 }
 
@@ -38,18 +127,178 @@ func (r *runDetails) ToggleColor() {
 func (r *runDetails) SendConnected() {
 	block := protocol.Block(*r.DefaultBlock)
 
-	// Set the text
-	text := fmt.Sprintf("down[%.2f kbs] up[%.2f kbs]", r.Down, r.Up)
-	if shortLength := textLength - len(text); shortLength > 0 {
-		text += strings.Repeat(" ", shortLength)
+	// Set the text, the client reports kilobits
+	var text string
+	now := time.Now()
+	switch {
+	case r.Mode == modeToday && r.usage != nil:
+		text = r.UsageText("today", r.usage.Day(r.Device, now))
+	case r.Mode == modeMonth && r.usage != nil:
+		text = r.UsageText(now.Format("Jan"), r.usage.Month(r.Device, now))
+	case r.Mode == modeLink && r.link != nil:
+		text = r.LinkText()
+		block.ShortText = fmt.Sprintf("%s %.0f%%", r.link.SSID, r.link.Quality)
+	default:
+		text = fmt.Sprintf("%s down[%s] up[%s]", r.Device,
+			r.FormatRate(r.Down), r.FormatRate(r.Up))
+		if r.conf.Sparkline > 0 {
+			text += " " + r.SparklineText()
+		}
+	}
+
+	// i3bar keeps the block as wide as the widest rates, so it
+	// doesn't jump about as they change
+	minWidth := fmt.Sprintf("%s down[%s] up[%s]", r.Device, widestRate, widestRate)
+	if r.conf.Sparkline > 0 {
+		minWidth += " " + strings.Repeat(string(sparks[len(sparks)-1]), r.conf.Sparkline)
 	}
 
 	block.FullText = text
-	block.MinWidth = textLength
+	block.MinWidthText = minWidth
 	block.Color = r.Color
+	if used := r.QuotaUsed(); used >= 1 {
+		block.Color = colors.Red
+		block.Urgent = true
+	} else if used >= quotaWarn {
+		block.Color = colors.Yellow
+	}
 	r.BlockChannel <- &block
 }
 
+// FormatRate formats a rate from the client, which reports
+// kilobits, in bits or bytes a second.
+func (r *runDetails) FormatRate(kilobits float64) string {
+	if r.Bytes {
+		return rateFormat.ByteRate(kilobits * 1000 / 8)
+	}
+	return rateFormat.BitRate(kilobits * 1000)
+}
+
+// SparklineText draws the last rates of the client.
+func (r *runDetails) SparklineText() string {
+	if r.client == nil {
+		return ""
+	}
+	return sparkline(r.client.History())
+}
+
+// sparkline draws rates, down and up together,
+// scaled to the highest of them.
+func sparkline(down, up []float64) string {
+	highest := 0.0
+	for i := range down {
+		if down[i]+up[i] > highest {
+			highest = down[i] + up[i]
+		}
+	}
+
+	line := make([]rune, len(down))
+	for i := range down {
+		level := 0
+		if highest > 0 {
+			level = int((down[i] + up[i]) / highest * float64(len(sparks)-1))
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
+
+// UsageText describes the bytes moved in a period,
+// e.g. "wlan0 today down[1.2 GiB] up[300.0 MiB]"
+func (r *runDetails) UsageText(period string, t wifibytes.Totals) string {
+	return fmt.Sprintf("%s %s down[%s] up[%s]", r.Device, period,
+		units.IEC.Bytes(float64(t.Rx)), units.IEC.Bytes(float64(t.Tx)))
+}
+
+// QuotaUsed returns the fraction of the quota used by the device,
+// or zero when there is no quota.
+func (r *runDetails) QuotaUsed() float64 {
+	if r.conf.Quota == 0 || r.usage == nil {
+		return 0
+	}
+
+	t := r.usage.Month(r.Device, time.Now())
+	if r.conf.QuotaDaily {
+		t = r.usage.Day(r.Device, time.Now())
+	}
+	return float64(t.Rx+t.Tx) / float64(r.conf.Quota)
+}
+
+// UpdateUsage adds the latest counters to the data usage and saves
+// it every saveInterval. Errors are logged once and not fatal.
+func (r *runDetails) UpdateUsage() {
+	if r.usage == nil {
+		return
+	}
+
+	err := r.usage.Update()
+	if err == nil && time.Since(r.lastSave) >= saveInterval {
+		err = r.usage.Save(wifibytes.UsagePath())
+		r.lastSave = time.Now()
+	}
+
+	r.errs.Log("couldn't account data usage", err)
+}
+
+// LinkText describes the wireless link, e.g. "HomeNet 72% 5GHz 433.3 Mb/s"
+func (r *runDetails) LinkText() string {
+	parts := []string{r.link.SSID, fmt.Sprintf("%.0f%%", r.link.Quality)}
+	if band := r.link.Band(); band != "" {
+		parts = append(parts, band)
+	}
+	if r.link.TxBitrate != 0 {
+		parts = append(parts, units.SI.BitRate(r.link.TxBitrate))
+	}
+	return strings.Join(parts, " ")
+}
+
+// HandleClick toggles the colour on button 1, switches between bits
+// and bytes on button 2 and cycles between the link, the rates and
+// the data used today and this month on button 3.
+func (r *runDetails) HandleClick(click *protocol.Click) {
+	switch click.Button {
+	case 1:
+		r.ToggleColor()
+	case 2:
+		r.Bytes = !r.Bytes
+	case 3:
+		// Skip what we can't show, the rates always can be
+		for {
+			r.Mode = (r.Mode + 1) % numModes
+			if r.Mode == modeLink && r.link == nil {
+				continue
+			}
+			if r.Mode >= modeToday && r.usage == nil {
+				continue
+			}
+			break
+		}
+	default:
+		return
+	}
+	r.SendConnected()
+}
+
+// UpdateLink reads the wireless details of the device. Devices which
+// aren't wireless, or aren't associated, have no link and we show
+// their rates instead. Other errors are logged once and not fatal,
+// the rates are still worth showing.
+func (r *runDetails) UpdateLink() {
+	r.link = nil
+	if r.Device == "" {
+		return
+	}
+
+	link, err := r.linkClient.GetLink(r.Device)
+	switch err {
+	case nil:
+		r.link = link
+	case wireless.ErrNotWireless, wireless.ErrNotConnected:
+		err = nil
+	}
+	r.errs.Log("couldn't read wireless link of "+r.Device, err)
+}
+
 func (r *runDetails) SendNotConnected() {
 	block := protocol.Block(*r.DefaultBlock)
 	block.FullText = "No Connection"
@@ -57,118 +306,331 @@ func (r *runDetails) SendNotConnected() {
 	r.BlockChannel <- &block
 }
 
+// SendNoInternet is sent when the link is up but the probe failed.
+func (r *runDetails) SendNoInternet() {
+	name := r.Device
+	if r.link != nil {
+		name = r.link.SSID
+	}
+
+	block := protocol.Block(*r.DefaultBlock)
+	block.FullText = name + " No Internet"
+	if r.portal {
+		block.FullText = name + " Captive Portal"
+	}
+	block.Color = colors.Yellow
+	r.BlockChannel <- &block
+}
+
+// UpdateState reads the link state of the device.
+// A device which has gone away is NotPresent.
+func (r *runDetails) UpdateState() {
+	if r.Device == "" {
+		r.State = linkstate.State{OperState: linkstate.NotPresent}
+		return
+	}
+
+	// Get only fails if the device vanished as we read it,
+	// the state then says it's down.
+	r.State, _ = linkstate.Get(r.Device)
+}
+
+// HandleEvent updates the link state when the device changes.
+// If the rtnetlink socket fails we fall back to polling.
+func (r *runDetails) HandleEvent(event *linkstate.Event, ok bool) {
+	if !ok {
+		logging.Error("link events stopped", fmt.Errorf("polling %s", netPath))
+		r.Events = nil
+		return
+	}
+
+	if event.Resync || event.Device == r.Device {
+		r.UpdateState()
+	}
+}
+
+// StartProbe runs the probe in the background. The channel is
+// buffered so the probe can always finish, even once we have
+// stopped waiting for it.
+func (r *runDetails) StartProbe() (<-chan error, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.conf.ProbeTimeout)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.conf.Probe.Probe(ctx)
+	}()
+	return errCh, cancel
+}
+
 func (r *runDetails) RecentTraffic() bool {
 	return r.Up != 0 || r.Down != 0
 }
 
-type stateFn func(*runDetails, *wifibytes.Client) stateFn
+// pickDevice chooses the interface to show. We return the current
+// device when nothing better is found, so that losing the default
+// route doesn't lose the interface we were watching.
+func (r *runDetails) pickDevice() string {
+	device, err := route.GetDefaultInterface()
+	if err != nil {
+		device = ""
+	}
+
+	if len(r.conf.Interfaces) == 0 {
+		if device == "" {
+			return r.Device
+		}
+		return device
+	}
+
+	for _, d := range r.conf.Interfaces {
+		if d == device {
+			return d
+		}
+	}
+
+	for _, d := range r.conf.Interfaces {
+		if _, err := os.Stat(netPath + d); err == nil {
+			return d
+		}
+	}
+
+	return r.Device
+}
+
+// Update follows the default route to a new interface if it
+// has moved and then reads the current speeds and wireless link.
+// The data usage of every interface is updated first.
+// With no interface at all the speeds are zero. Without link
+// events the link state is polled here too.
+func (r *runDetails) Update() error {
+	r.UpdateUsage()
 
-func notConnected(r *runDetails, c *wifibytes.Client) stateFn {
+	if device := r.pickDevice(); device != r.Device || r.client == nil {
+		r.Device = device
+		r.client = nil
+		r.Down, r.Up = 0, 0
+		r.UpdateState()
+		if device == "" {
+			return nil
+		}
+
+		client, err := wifibytes.NewClient(numReads, device)
+		if err != nil {
+			return fmt.Errorf("couldn't create client for %s :: %v", device, err)
+		}
+		client.KeepHistory(r.conf.Sparkline)
+		r.client = client
+	}
+
+	var err error
+	r.Down, r.Up, err = r.client.GetKilobitsPerSecond()
+	if err != nil {
+		return fmt.Errorf("couldn't get speeds for %s :: %v", r.Device, err)
+	}
+
+	if r.Events == nil {
+		r.UpdateState()
+	}
+
+	r.UpdateLink()
+	return nil
+}
+
+// The states are
+//
+//	notConnected:   the link is down or has no address
+//	testConnection: the link is up and we are probing the internet
+//	connected:      the internet was reachable or traffic is flowing
+//	noInternet:     the link is up but the probe failed, we probe
+//	                again with backoff until it passes
+//
+// Link state changes come from rtnetlink and move us between
+// notConnected and the rest. Traffic only decides whether we probe.
+type stateFn func(*runDetails) stateFn
+
+func notConnected(r *runDetails) stateFn {
 	r.SendNotConnected()
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 	for {
-		var err error
 		select {
 		case <-ticker.C:
-			r.Down, r.Up, err = c.GetKilobitsPerSecond()
-			if err != nil {
-				r.err = fmt.Errorf("couldn't get speed :: %v", err)
+			r.err = r.Update()
+			if r.err != nil {
 				return nil
 			}
 
-			if r.RecentTraffic() {
-				return connected
-			}
+		case event, ok := <-r.Events:
+			r.HandleEvent(event, ok)
 
 		case <-r.ClickChannel:
 			continue
 		}
+
+		if r.State.Connected() {
+			return testConnection
+		}
 	}
 }
 
-func connected(r *runDetails, c *wifibytes.Client) stateFn {
+func connected(r *runDetails) stateFn {
+	r.portal = false
 	r.SendConnected()
 
+	// An idle link is fine once the probe has passed, we only
+	// probe again when traffic stops after flowing.
+	sawTraffic := r.RecentTraffic()
+
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 	for {
-		var err error
 		select {
 		case <-ticker.C:
-			r.Down, r.Up, err = c.GetKilobitsPerSecond()
-			if err != nil {
-				r.err = fmt.Errorf("couldn't get speeds :: %v", err)
+			r.err = r.Update()
+			if r.err != nil {
 				return nil
 			}
+			if !r.State.Connected() {
+				return notConnected
+			}
 			r.SendConnected()
 
-			if !r.RecentTraffic() {
+			if r.RecentTraffic() {
+				sawTraffic = true
+			} else if sawTraffic {
 				return testConnection
 			}
 
+		case event, ok := <-r.Events:
+			r.HandleEvent(event, ok)
+			if !r.State.Connected() {
+				return notConnected
+			}
+
 		case click := <-r.ClickChannel:
-			if click.Button != 1 {
-				continue
+			r.HandleClick(click)
+		}
+	}
+}
+
+func testConnection(r *runDetails) stateFn {
+	r.SendConnected()
+
+	errCh, cancel := r.StartProbe()
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.err = r.Update()
+			if r.err != nil {
+				return nil
+			}
+			if !r.State.Connected() {
+				return notConnected
 			}
-			r.ToggleColor()
 			r.SendConnected()
+
+		case event, ok := <-r.Events:
+			r.HandleEvent(event, ok)
+			if !r.State.Connected() {
+				return notConnected
+			}
+
+		case click := <-r.ClickChannel:
+			r.HandleClick(click)
+
+		case err := <-errCh:
+			r.portal = err == probe.ErrCaptivePortal
+			if err != nil {
+				return noInternet
+			}
+			return connected
 		}
 	}
 }
 
-func testConnection(r *runDetails, c *wifibytes.Client) stateFn {
-	errCh := make(chan error)
-	go func() {
-		cmd := exec.Command("ping", "-c", "1", "8.8.8.8")
-		errCh <- cmd.Run()
-	}()
+func noInternet(r *runDetails) stateFn {
+	r.SendNoInternet()
 
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
-	for n := 0; n < 20; n++ {
-		var err error
 
+	retry := retryFirst
+	timer := time.NewTimer(retry)
+	defer timer.Stop()
+
+	// errCh is nil, and never ready, while no probe is running
+	var errCh <-chan error
+	cancel := context.CancelFunc(func() {})
+	defer func() { cancel() }()
+
+	// The probe's own packets are in the rates until they have
+	// been averaged away, so only later traffic counts
+	quiet := 0
+	for {
 		select {
 		case <-ticker.C:
-			r.Down, r.Up, err = c.GetKilobitsPerSecond()
-			if err != nil {
-				r.err = fmt.Errorf("couldn't get speeds :: %v", err)
+			r.err = r.Update()
+			if r.err != nil {
 				return nil
 			}
+			if !r.State.Connected() {
+				return notConnected
+			}
 
-			if r.RecentTraffic() {
+			quiet++
+			if errCh == nil && quiet > numReads && r.RecentTraffic() {
 				return connected
 			}
 
-		case click := <-r.ClickChannel:
-			if click.Button != 1 {
-				continue
+		case <-timer.C:
+			errCh, cancel = r.StartProbe()
+			quiet = 0
+
+		case err := <-errCh:
+			cancel()
+			if err == nil {
+				return connected
 			}
-			r.ToggleColor()
-			r.SendConnected()
-		case err = <-errCh:
-			if err != nil {
+
+			errCh = nil
+			quiet = 0
+			r.portal = err == probe.ErrCaptivePortal
+			r.SendNoInternet()
+
+			retry *= 2
+			if retry > retryMax {
+				retry = retryMax
+			}
+			timer.Reset(retry)
+
+		case event, ok := <-r.Events:
+			r.HandleEvent(event, ok)
+			if !r.State.Connected() {
 				return notConnected
 			}
-			// err == nil implies ping exited with status 0
-			// This only happens if it was succesful
-			return connected
+
+		case <-r.ClickChannel:
+			continue
 		}
 	}
-
-	// Still no bytes after twenty seconds.
-	// Ping has not exited after twenty seconds
-	// Assume we've disconnected
-	return notConnected
 }
 
 func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
-	c, err := wifibytes.NewClient(10, device)
-	if err != nil {
-		err = fmt.Errorf("couldn't create client :: %v", err)
-		return err
+	return DefaultConfig.Run(defaultBlock, in, out)
+}
+
+// Run is a blocks.RunFunc using the settings in c.
+func (c Config) Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *protocol.Block) error {
+	if c.Probe == nil {
+		c.Probe = DefaultConfig.Probe
+	}
+	if c.ProbeTimeout == 0 {
+		c.ProbeTimeout = DefaultConfig.ProbeTimeout
 	}
 
 	r := &runDetails{
@@ -176,11 +638,40 @@ func Run(defaultBlock *protocol.Block, in <-chan *protocol.Click, out chan<- *pr
 		ClickChannel: in,
 		BlockChannel: out,
 		Color:        colors.Grey,
+		Bytes:        c.Bytes,
+		conf:         &c,
+		linkClient:   wireless.NewClient(),
 	}
+	defer r.linkClient.Close()
 
-	state := notConnected
+	if c.Accounting {
+		// Rather than overwrite a file we can't read, go without
+		usage, err := wifibytes.LoadUsage(wifibytes.UsagePath())
+		if err != nil {
+			logging.Error("couldn't load data usage, not accounting", err)
+		}
+		r.usage = usage
+	}
+
+	watcher, err := linkstate.Watch()
+	if err != nil {
+		logging.Error("couldn't watch link events, polling "+netPath, err)
+	} else {
+		defer watcher.Close()
+		r.Events = watcher.Events
+	}
+
+	err = r.Update()
+	if err != nil {
+		return err
+	}
+
+	var state stateFn = notConnected
+	if r.State.Connected() {
+		state = testConnection
+	}
 	for {
-		state = state(r, c)
+		state = state(r)
 		if r.err != nil {
 			return r.err
 		}
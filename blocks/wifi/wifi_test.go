@@ -0,0 +1,30 @@
+package wifi
+
+import "testing"
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		name     string
+		down, up []float64
+		want     string
+	}{
+		{"empty", nil, nil, ""},
+		{"all zero", []float64{0, 0, 0}, []float64{0, 0, 0}, "▁▁▁"},
+		{"oldest first", []float64{0, 4, 8}, []float64{0, 0, 0}, "▁▄█"},
+		{"down and up together", []float64{1, 0}, []float64{0, 1}, "██"},
+		{"unread are zero", []float64{0, 0, 7}, []float64{0, 0, 7}, "▁▁█"},
+	}
+
+	for _, test := range tests {
+		if got := sparkline(test.down, test.up); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSparklineTextWithoutClient(t *testing.T) {
+	r := &runDetails{conf: &Config{Sparkline: 5}}
+	if got := r.SparklineText(); got != "" {
+		t.Errorf("got %q without a client, want empty", got)
+	}
+}
@@ -0,0 +1,212 @@
+package wifibytes
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	bootIdPath = "/proc/sys/kernel/random/boot_id"
+
+	dayFormat   = "2006-01-02"
+	monthFormat = "2006-01"
+
+	// How much history we keep in the state file
+	keepDays   = 62
+	keepMonths = 24
+)
+
+// Totals is a count of bytes received and sent.
+type Totals struct {
+	Rx uint64 `json:"rx"`
+	Tx uint64 `json:"tx"`
+}
+
+// Usage accumulates the bytes each interface has moved per day and
+// per month, in local time. It is saved as JSON between runs.
+//
+//	usage, err := wifibytes.LoadUsage(wifibytes.UsagePath())
+//	// .. handle error
+//	err = usage.Update()
+//	// .. handle error
+//	today := usage.Day("wlan0", time.Now())
+//	err = usage.Save(wifibytes.UsagePath())
+type Usage struct {
+	// BootId tells us whether Last was read since the last reboot
+	BootId string                       `json:"boot_id"`
+	Last   map[string]Totals            `json:"last"`
+	Days   map[string]map[string]Totals `json:"days"`
+	Months map[string]map[string]Totals `json:"months"`
+}
+
+// UsagePath returns $XDG_STATE_HOME/clark/netusage.json,
+// where XDG_STATE_HOME defaults to ~/.local/state
+func UsagePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		dir = filepath.Join(os.Getenv("HOME"), ".local", "state")
+	}
+	return filepath.Join(dir, "clark", "netusage.json")
+}
+
+// LoadUsage reads the usage saved at filePath.
+// A missing file is an empty Usage.
+func LoadUsage(filePath string) (*Usage, error) {
+	u := &Usage{}
+
+	b, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(b, u); err != nil {
+			return nil, err
+		}
+	}
+
+	if u.Last == nil {
+		u.Last = map[string]Totals{}
+	}
+	if u.Days == nil {
+		u.Days = map[string]map[string]Totals{}
+	}
+	if u.Months == nil {
+		u.Months = map[string]map[string]Totals{}
+	}
+
+	return u, nil
+}
+
+// Save writes the usage to filePath. It writes a temporary file and
+// renames it so a crash never leaves half a file behind.
+func (u *Usage) Save(filePath string) error {
+	err := os.MkdirAll(filepath.Dir(filePath), 0755)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+
+	tmp := filePath + ".tmp"
+	err = ioutil.WriteFile(tmp, b, 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filePath)
+}
+
+// Update reads /proc/net/dev and adds what every interface has
+// moved since the last call to today and this month.
+func (u *Usage) Update() error {
+	stats, err := GetStats()
+	if err != nil {
+		return err
+	}
+
+	b, err := ioutil.ReadFile(bootIdPath)
+	if err != nil {
+		return err
+	}
+
+	u.Add(stats, strings.TrimSpace(string(b)), time.Now())
+	return nil
+}
+
+// Add adds the counters in stats. After a reboot, or when the
+// counters of an interface went backwards, they have started again
+// from zero and all of them are counted. On the first run, or for an
+// interface we haven't seen this boot, we don't know what was already
+// counted so they are only remembered. Interfaces which have gone
+// away are forgotten.
+func (u *Usage) Add(stats map[string]*DevStats, bootId string, now time.Time) {
+	firstRun := u.BootId == ""
+	rebooted := !firstRun && bootId != u.BootId
+	if bootId != u.BootId {
+		u.BootId = bootId
+		u.Last = map[string]Totals{}
+	}
+
+	for device := range u.Last {
+		if _, ok := stats[device]; !ok {
+			delete(u.Last, device)
+		}
+	}
+
+	day := now.Format(dayFormat)
+	month := now.Format(monthFormat)
+	for device, s := range stats {
+		if device == "lo" {
+			continue
+		}
+
+		current := Totals{Rx: s.RxBytes, Tx: s.TxBytes}
+		last, seen := u.Last[device]
+		u.Last[device] = current
+
+		switch {
+		case !seen && !rebooted:
+			continue
+		case current.Rx < last.Rx || current.Tx < last.Tx:
+			last = Totals{}
+		}
+
+		moved := Totals{Rx: current.Rx - last.Rx, Tx: current.Tx - last.Tx}
+		if moved.Rx == 0 && moved.Tx == 0 {
+			continue
+		}
+
+		addTotals(u.Days, day, device, moved)
+		addTotals(u.Months, month, device, moved)
+	}
+
+	u.prune(now)
+}
+
+func addTotals(periods map[string]map[string]Totals, period, device string, moved Totals) {
+	devices, ok := periods[period]
+	if !ok {
+		devices = map[string]Totals{}
+		periods[period] = devices
+	}
+
+	t := devices[device]
+	t.Rx += moved.Rx
+	t.Tx += moved.Tx
+	devices[device] = t
+}
+
+// prune forgets old days and months. The keys sort by date
+// so they can be compared as strings.
+func (u *Usage) prune(now time.Time) {
+	oldestDay := now.AddDate(0, 0, -keepDays).Format(dayFormat)
+	for day := range u.Days {
+		if day < oldestDay {
+			delete(u.Days, day)
+		}
+	}
+
+	oldestMonth := now.AddDate(0, -keepMonths, 0).Format(monthFormat)
+	for month := range u.Months {
+		if month < oldestMonth {
+			delete(u.Months, month)
+		}
+	}
+}
+
+// Day returns what device moved on the day of t.
+func (u *Usage) Day(device string, t time.Time) Totals {
+	return u.Days[t.Format(dayFormat)][device]
+}
+
+// Month returns what device moved in the month of t.
+func (u *Usage) Month(device string, t time.Time) Totals {
+	return u.Months[t.Format(monthFormat)][device]
+}
@@ -0,0 +1,150 @@
+package wifibytes
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newUsage() *Usage {
+	return &Usage{
+		Last:   map[string]Totals{},
+		Days:   map[string]map[string]Totals{},
+		Months: map[string]map[string]Totals{},
+	}
+}
+
+func stats(rx, tx uint64) map[string]*DevStats {
+	return map[string]*DevStats{
+		"lo":    {RxBytes: 5000, TxBytes: 5000},
+		"wlan0": {RxBytes: rx, TxBytes: tx},
+	}
+}
+
+var noon = time.Date(2024, time.March, 15, 12, 0, 0, 0, time.Local)
+
+func TestAddFirstRun(t *testing.T) {
+	u := newUsage()
+	u.Add(stats(1000, 100), "boot-a", noon)
+
+	if got := u.Day("wlan0", noon); got != (Totals{}) {
+		t.Errorf("first run counted %+v, want only a baseline", got)
+	}
+	if got := u.Last["wlan0"]; got != (Totals{Rx: 1000, Tx: 100}) {
+		t.Errorf("baseline is %+v, want {1000 100}", got)
+	}
+
+	u.Add(stats(1500, 150), "boot-a", noon)
+	if got := u.Day("wlan0", noon); got != (Totals{Rx: 500, Tx: 50}) {
+		t.Errorf("got %+v after the baseline, want {500 50}", got)
+	}
+	if got := u.Month("wlan0", noon); got != (Totals{Rx: 500, Tx: 50}) {
+		t.Errorf("got %+v for the month, want {500 50}", got)
+	}
+	if _, ok := u.Days[noon.Format(dayFormat)]["lo"]; ok {
+		t.Error("counted lo")
+	}
+}
+
+func TestAddReboot(t *testing.T) {
+	u := newUsage()
+	u.Add(stats(1000, 100), "boot-a", noon)
+	u.Add(stats(3000, 300), "boot-a", noon)
+
+	// Everything since the reboot is new
+	u.Add(stats(400, 40), "boot-b", noon)
+	if got := u.Day("wlan0", noon); got != (Totals{Rx: 2400, Tx: 240}) {
+		t.Errorf("got %+v after a reboot, want {2400 240}", got)
+	}
+}
+
+func TestAddCounterReset(t *testing.T) {
+	u := newUsage()
+	u.Add(stats(1000, 100), "boot-a", noon)
+	u.Add(stats(3000, 300), "boot-a", noon)
+
+	// The driver was reloaded
+	u.Add(stats(200, 20), "boot-a", noon)
+	if got := u.Day("wlan0", noon); got != (Totals{Rx: 2200, Tx: 220}) {
+		t.Errorf("got %+v after a reset, want {2200 220}", got)
+	}
+}
+
+func TestAddNewInterface(t *testing.T) {
+	u := newUsage()
+	u.Add(stats(1000, 100), "boot-a", noon)
+
+	all := stats(1000, 100)
+	all["usb0"] = &DevStats{RxBytes: 9000, TxBytes: 900}
+	u.Add(all, "boot-a", noon)
+	if got := u.Day("usb0", noon); got != (Totals{}) {
+		t.Errorf("new interface counted %+v, want only a baseline", got)
+	}
+
+	all["usb0"] = &DevStats{RxBytes: 9500, TxBytes: 950}
+	u.Add(all, "boot-a", noon)
+	if got := u.Day("usb0", noon); got != (Totals{Rx: 500, Tx: 50}) {
+		t.Errorf("got %+v, want {500 50}", got)
+	}
+
+	// Gone interfaces are forgotten
+	u.Add(stats(1000, 100), "boot-a", noon)
+	if _, ok := u.Last["usb0"]; ok {
+		t.Error("kept the last counters of a removed interface")
+	}
+}
+
+func TestAddPeriods(t *testing.T) {
+	endOfMonth := time.Date(2024, time.March, 31, 23, 59, 0, 0, time.Local)
+	nextDay := endOfMonth.Add(2 * time.Minute)
+
+	u := newUsage()
+	u.Add(stats(1000, 100), "boot-a", endOfMonth)
+	u.Add(stats(2000, 200), "boot-a", endOfMonth)
+	u.Add(stats(2500, 250), "boot-a", nextDay)
+
+	if got := u.Day("wlan0", endOfMonth); got != (Totals{Rx: 1000, Tx: 100}) {
+		t.Errorf("got %+v on the 31st, want {1000 100}", got)
+	}
+	if got := u.Day("wlan0", nextDay); got != (Totals{Rx: 500, Tx: 50}) {
+		t.Errorf("got %+v on the 1st, want {500 50}", got)
+	}
+	if got := u.Month("wlan0", endOfMonth); got != (Totals{Rx: 1000, Tx: 100}) {
+		t.Errorf("got %+v for March, want {1000 100}", got)
+	}
+	if got := u.Month("wlan0", nextDay); got != (Totals{Rx: 500, Tx: 50}) {
+		t.Errorf("got %+v for April, want {500 50}", got)
+	}
+
+	// Old days are pruned, months are kept longer
+	u.Add(stats(2500, 250), "boot-a", nextDay.AddDate(0, 0, keepDays+1))
+	if _, ok := u.Days[endOfMonth.Format(dayFormat)]; ok {
+		t.Error("kept a day older than keepDays")
+	}
+	if got := u.Month("wlan0", endOfMonth); got != (Totals{Rx: 1000, Tx: 100}) {
+		t.Errorf("got %+v for March after pruning, want {1000 100}", got)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clark", "netusage.json")
+
+	u, err := LoadUsage(path)
+	if err != nil {
+		t.Fatalf("couldn't load a missing file :: %v", err)
+	}
+	u.Add(stats(1000, 100), "boot-a", noon)
+	u.Add(stats(1500, 150), "boot-a", noon)
+
+	if err := u.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadUsage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.BootId != "boot-a" || loaded.Day("wlan0", noon) != (Totals{Rx: 500, Tx: 50}) {
+		t.Errorf("loaded %+v, want what was saved", loaded)
+	}
+}
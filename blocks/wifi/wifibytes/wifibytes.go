@@ -19,32 +19,72 @@ const (
 )
 
 type (
+	// DevStats holds every counter of a single interface
+	// in /proc/net/dev, in the order they appear there.
+	DevStats struct {
+		RxBytes      uint64
+		RxPackets    uint64
+		RxErrs       uint64
+		RxDrop       uint64
+		RxFifo       uint64
+		RxFrame      uint64
+		RxCompressed uint64
+		RxMulticast  uint64
+		TxBytes      uint64
+		TxPackets    uint64
+		TxErrs       uint64
+		TxDrop       uint64
+		TxFifo       uint64
+		TxColls      uint64
+		TxCarrier    uint64
+		TxCompressed uint64
+	}
+
 	// singleRead holds data for a specific read
 	// of /proc/net/dev. It holds the total number
 	// of bytes sent over the interface from uptime.
 	singleRead struct {
-		bytesUp   int
-		bytesDown int
-		readTime  time.Time
+		stats    *DevStats
+		readTime time.Time
 	}
 
 	Client struct {
 		reads  []*singleRead
 		pos    int
 		device string
+
+		// history is a ring of the rates between each
+		// read and the one before, histPos is the oldest
+		history []rate
+		histPos int
+	}
+
+	// rate is kilobits per second down and up
+	rate struct {
+		down float64
+		up   float64
 	}
 )
 
+// fields returns pointers to the counters in file order.
+func (d *DevStats) fields() []*uint64 {
+	return []*uint64{
+		&d.RxBytes, &d.RxPackets, &d.RxErrs, &d.RxDrop,
+		&d.RxFifo, &d.RxFrame, &d.RxCompressed, &d.RxMulticast,
+		&d.TxBytes, &d.TxPackets, &d.TxErrs, &d.TxDrop,
+		&d.TxFifo, &d.TxColls, &d.TxCarrier, &d.TxCompressed,
+	}
+}
+
 func newSingleRead(device string) (*singleRead, error) {
-	bytesDown, bytesUp, err := readNetDevFile(path, device)
+	stats, err := readNetDevFile(path, device)
 	if err != nil {
 		return nil, err
 	}
 
 	return &singleRead{
-		readTime:  time.Now(),
-		bytesUp:   bytesUp,
-		bytesDown: bytesDown,
+		readTime: time.Now(),
+		stats:    stats,
 	}, nil
 }
 
@@ -53,17 +93,68 @@ func newSingleRead(device string) (*singleRead, error) {
 // The caller is expected to know how big the internal buffer and
 // how long between calls are used.
 func (c *Client) GetKilobitsPerSecond() (float64, float64, error) {
+	n := len(c.reads)
+	newest := *c.reads[(c.pos+n-1)%n]
+
 	r := c.reads[c.pos]
 	down, up, err := r.getKilobitsSecond(c.device)
 	if err != nil {
 		return 0, 0, err
 	}
 
-	c.pos = (c.pos + 1) % len(c.reads)
+	c.pos = (c.pos + 1) % n
+	c.record(rateBetween(&newest, r))
 
 	return down, up, nil
 }
 
+// KeepHistory sets how many rates History returns, which doesn't
+// change the number of reads GetKilobitsPerSecond averages over.
+// The rates kept so far are lost.
+func (c *Client) KeepHistory(length int) {
+	c.history = make([]rate, length)
+	c.histPos = 0
+}
+
+// History returns the kilobits per second down and up between each
+// read and the one before, oldest first. Until KeepHistory is called
+// there is one fewer than the size of the buffer, those not yet
+// read are zero.
+func (c *Client) History() ([]float64, []float64) {
+	n := len(c.history)
+
+	down := make([]float64, n)
+	up := make([]float64, n)
+	for i := range down {
+		r := c.history[(c.histPos+i)%n]
+		down[i], up[i] = r.down, r.up
+	}
+
+	return down, up
+}
+
+// record adds a rate to the history, replacing the oldest.
+func (c *Client) record(r rate) {
+	if len(c.history) == 0 {
+		return
+	}
+
+	c.history[c.histPos] = r
+	c.histPos = (c.histPos + 1) % len(c.history)
+}
+
+// rateBetween returns the rate between two reads.
+func rateBetween(before, after *singleRead) rate {
+	period := after.readTime.Sub(before.readTime).Seconds()
+	if period <= 0 {
+		return rate{}
+	}
+
+	changeDown := float64(delta(before.stats.RxBytes, after.stats.RxBytes)) * 8 / 1000
+	changeUp := float64(delta(before.stats.TxBytes, after.stats.TxBytes)) * 8 / 1000
+	return rate{down: changeDown / period, up: changeUp / period}
+}
+
 // NewClient returns a new client ready for reading
 // numReads is how big the user wishes the buffer
 // of previous data points to be.
@@ -79,18 +170,39 @@ func NewClient(numReads int, device string) (*Client, error) {
 	}
 
 	return &Client{
-		reads:  reads,
-		pos:    0,
-		device: device,
+		reads:   reads,
+		pos:     0,
+		device:  device,
+		history: make([]rate, numReads-1),
 	}, nil
 }
 
+// GetStats will read the counters of every interface in /proc/net/dev.
+func GetStats() (map[string]*DevStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseNetDev(file)
+}
+
+// delta returns how far a counter has moved. The kernel resets the
+// counters when a driver is reloaded, so a counter going backwards
+// counts as no change rather than a negative one.
+func delta(before, after uint64) uint64 {
+	if after < before {
+		return 0
+	}
+	return after - before
+}
+
 // getKilobitsSecond will refresh the singleRead instance to
 // the current value of bytes over the interface. It will
 // return the computed kilobits per second since the last time
 // getKilobitsSecond was called.
 func (s *singleRead) getKilobitsSecond(device string) (float64, float64, error) {
-	bytesDown, bytesUp, err := readNetDevFile(path, device)
+	stats, err := readNetDevFile(path, device)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -99,8 +211,8 @@ func (s *singleRead) getKilobitsSecond(device string) (float64, float64, error)
 	period := readTime.Sub(s.readTime).Seconds()
 
 	// Compute how many bits over the wire
-	changeDown := float64(bytesDown-s.bytesDown) * 8
-	changeUp := float64(bytesUp-s.bytesUp) * 8
+	changeDown := float64(delta(s.stats.RxBytes, stats.RxBytes)) * 8
+	changeUp := float64(delta(s.stats.TxBytes, stats.TxBytes)) * 8
 
 	// Compute how many kilobits over the wire
 	changeDown = changeDown / 1000
@@ -112,60 +224,76 @@ func (s *singleRead) getKilobitsSecond(device string) (float64, float64, error)
 
 	// Set s to the current read
 	s.readTime = readTime
-	s.bytesUp = bytesUp
-	s.bytesDown = bytesDown
+	s.stats = stats
 
 	return down, up, nil
 }
 
-// readNetDevFile attempts to parse a file for number of
-// bytes (up and down) for a given device.
-func readNetDevFile(filePath, device string) (int, int, error) {
+// readNetDevFile attempts to parse a file for the
+// counters of a given device.
+func readNetDevFile(filePath, device string) (*DevStats, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return 0, 0, err
+		return nil, err
 	}
 	defer file.Close()
-	return readNetworkBytes(file, device)
+
+	all, err := parseNetDev(file)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, ok := all[device]
+	if !ok {
+		return nil, fmt.Errorf("couldn't find line for %s", device)
+	}
+
+	return stats, nil
 }
 
-// readNetworkBytes attempts to read the total number of bytes for
-// a given device from an io.Reader. It returns a triplet.
-// bytesUp, bytesDown and thirdly any errors encountered.
-func readNetworkBytes(reader io.Reader, device string) (int, int, error) {
-	var err error
+// parseNetDev reads the counters of every interface from an io.Reader.
+// After two header lines each line looks like
+//
+//	eth0: 1188 17 0 0 0 0 0 0 1489 17 0 0 0 0 0 0
+//
+// We split on the colon, so "eth0" never matches "eth0.100".
+func parseNetDev(reader io.Reader) (map[string]*DevStats, error) {
 	scanner := bufio.NewScanner(reader)
 	scanner.Split(bufio.ScanLines)
 
+	all := map[string]*DevStats{}
 	for scanner.Scan() {
 		line := scanner.Text()
-		if !strings.HasPrefix(line, device) {
+		colon := strings.IndexByte(line, ':')
+		if colon < 0 {
+			// A header line
 			continue
 		}
 
-		fields := strings.Fields(line)
-		if len(fields) < 10 {
-			return 0, 0, fmt.Errorf("not enough fields in %s for %s",
-				path, device)
-		}
+		device := strings.TrimSpace(line[:colon])
+		stats := &DevStats{}
 
-		bytesDown, err := strconv.Atoi(fields[1])
-		if err != nil {
-			return 0, 0, err
+		counters := stats.fields()
+		fields := strings.Fields(line[colon+1:])
+		if len(fields) < len(counters) {
+			return nil, fmt.Errorf("not enough fields in %s for %s",
+				path, device)
 		}
 
-		bytesUp, err := strconv.Atoi(fields[9])
-		if err != nil {
-			return 0, 0, err
+		for i, counter := range counters {
+			var err error
+			*counter, err = strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, err
+			}
 		}
 
-		return bytesDown, bytesUp, nil
+		all[device] = stats
 	}
 
-	if err = scanner.Err(); err != nil {
-		return 0, 0, err
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	/* couldn't find line for this device */
-	return 0, 0, fmt.Errorf("couldn't find line for %s", device)
+	return all, nil
 }
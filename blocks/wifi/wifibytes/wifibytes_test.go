@@ -0,0 +1,140 @@
+package wifibytes
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	c := &Client{}
+	c.KeepHistory(3)
+
+	down, up := c.History()
+	if !reflect.DeepEqual(down, []float64{0, 0, 0}) || !reflect.DeepEqual(up, []float64{0, 0, 0}) {
+		t.Errorf("unread history is %v %v, want zeros", down, up)
+	}
+
+	c.record(rate{down: 1, up: 10})
+	c.record(rate{down: 2, up: 20})
+	down, up = c.History()
+	if !reflect.DeepEqual(down, []float64{0, 1, 2}) || !reflect.DeepEqual(up, []float64{0, 10, 20}) {
+		t.Errorf("got %v %v, want [0 1 2] [0 10 20]", down, up)
+	}
+
+	// The oldest is replaced once the ring is full
+	c.record(rate{down: 3, up: 30})
+	c.record(rate{down: 4, up: 40})
+	down, up = c.History()
+	if !reflect.DeepEqual(down, []float64{2, 3, 4}) || !reflect.DeepEqual(up, []float64{20, 30, 40}) {
+		t.Errorf("got %v %v, want [2 3 4] [20 30 40]", down, up)
+	}
+}
+
+func TestHistoryEmpty(t *testing.T) {
+	c := &Client{}
+	c.record(rate{down: 1, up: 1})
+
+	down, up := c.History()
+	if len(down) != 0 || len(up) != 0 {
+		t.Errorf("got %v %v, want no history", down, up)
+	}
+}
+
+func TestRateBetween(t *testing.T) {
+	now := time.Now()
+	before := &singleRead{stats: &DevStats{RxBytes: 1000, TxBytes: 500}, readTime: now}
+	after := &singleRead{stats: &DevStats{RxBytes: 3000, TxBytes: 750}, readTime: now.Add(2 * time.Second)}
+
+	// 2000 bytes in 2 seconds is 8 kilobits a second
+	if got := rateBetween(before, after); got != (rate{down: 8, up: 1}) {
+		t.Errorf("got %+v, want {down:8 up:1}", got)
+	}
+
+	// A counter reset isn't a negative rate
+	if got := rateBetween(after, before); got != (rate{}) {
+		t.Errorf("got %+v backwards, want zero", got)
+	}
+}
+
+// netDev is /proc/net/dev with a vlan on eth0 and two wl devices
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:   52890     620    0    0    0     0          0         0    52890     620    0    0    0     0       0          0
+  eth0: 1188000    1700    0    3    0     0          0        12  1489000    1500    0    0    0     0       0          0
+eth0.100:  24000      40    0    0    0     0          0         0     8000      20    0    0    0     0       0          0
+wlp2s0:987654321  700000    0   14    0     0          0         0 12345678  300000    0    0    0     0       0          0
+ wlan0:       0       0    0    0    0     0          0         0        0       0    0    0    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	all, err := parseNetDev(strings.NewReader(netDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		device string
+		found  bool
+		rx, tx uint64
+	}{
+		{device: "eth0", found: true, rx: 1188000, tx: 1489000},
+		{device: "eth0.100", found: true, rx: 24000, tx: 8000},
+		{device: "wlp2s0", found: true, rx: 987654321, tx: 12345678},
+		{device: "wlan0", found: true},
+		{device: "wl"},
+		{device: "eth"},
+	}
+
+	for _, test := range tests {
+		stats, ok := all[test.device]
+		if ok != test.found {
+			t.Errorf("%s: found %v, want %v", test.device, ok, test.found)
+			continue
+		}
+		if ok && (stats.RxBytes != test.rx || stats.TxBytes != test.tx) {
+			t.Errorf("%s: got %d %d, want %d %d", test.device,
+				stats.RxBytes, stats.TxBytes, test.rx, test.tx)
+		}
+	}
+
+	if got := all["eth0"].RxDrop; got != 3 {
+		t.Errorf("eth0 RxDrop got %d, want 3", got)
+	}
+	if got := all["eth0"].RxMulticast; got != 12 {
+		t.Errorf("eth0 RxMulticast got %d, want 12", got)
+	}
+	if got := all["wlp2s0"].TxPackets; got != 300000 {
+		t.Errorf("wlp2s0 TxPackets got %d, want 300000", got)
+	}
+}
+
+func TestParseNetDevBad(t *testing.T) {
+	for _, line := range []string{
+		"  eth0: 1188 17 0 0\n",
+		"  eth0: 1188 17 0 0 0 0 0 0 1489 17 0 0 0 0 0 x\n",
+	} {
+		if _, err := parseNetDev(strings.NewReader(line)); err == nil {
+			t.Errorf("no error for %q", line)
+		}
+	}
+}
+
+func TestDelta(t *testing.T) {
+	tests := []struct {
+		before, after, want uint64
+	}{
+		{before: 1000, after: 3000, want: 2000},
+		{before: 3000, after: 3000, want: 0},
+		// The interface was recreated and its counters reset
+		{before: 3000, after: 100, want: 0},
+		{before: 1<<64 - 1, after: 0, want: 0},
+	}
+
+	for _, test := range tests {
+		if got := delta(test.before, test.after); got != test.want {
+			t.Errorf("delta(%d, %d) got %d, want %d", test.before, test.after, got, test.want)
+		}
+	}
+}
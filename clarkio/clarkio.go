@@ -10,10 +10,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
 	"time"
 
 	"github.com/jameswelchman/clark/conf"
+	"github.com/jameswelchman/clark/logging"
 	"github.com/jameswelchman/clark/protocol"
 )
 
@@ -41,14 +41,14 @@ func ReadClicks(reader io.Reader, clickChannels map[string]chan<- *protocol.Clic
 		// on our reader.
 		err = decoder.Decode(click)
 		if err != nil {
-			logError("failed to decode from stdin", err)
+			logging.Error("failed to decode from stdin", err)
 			continue
 		}
 
 		key := click.Name + "_" + click.Instance
 		in, ok := clickChannels[key]
 		if !ok {
-			logName("couldn't find %s in channel map", key)
+			logging.Log(fmt.Sprintf("couldn't find %s in channel map", key))
 			continue
 		}
 
@@ -63,20 +63,20 @@ func drainArrayStart(decoder *json.Decoder) error {
 	// Take the first [
 	tk, err := decoder.Token()
 	if err != nil {
-		logError("couldn't read from stdin", err)
+		logging.Error("couldn't read from stdin", err)
 		return err
 	}
 
 	delim, ok := tk.(json.Delim)
 	if !ok {
 		msg := "first token not a delim"
-		log(msg)
+		logging.Log(msg)
 		return errors.New(msg)
 	}
 	// Make sure the value of the delim is '['
 	if delim != json.Delim('[') {
 		msg := "first token not a ["
-		log(msg)
+		logging.Log(msg)
 		return errors.New(msg)
 	}
 
@@ -125,7 +125,7 @@ func WriteBlocks(writer io.Writer, blockChannels []<-chan *protocol.Block) {
 
 				err := buffer.Flush()
 				if err != nil {
-					logError("failed to flush", err)
+					logging.Error("failed to flush", err)
 				}
 
 				numUpdates = 0
@@ -137,7 +137,7 @@ func WriteBlocks(writer io.Writer, blockChannels []<-chan *protocol.Block) {
 func writeWithError(w io.Writer, p []byte) {
 	_, err := w.Write(p)
 	if err != nil {
-		logError("failed to write", err)
+		logging.Error("failed to write", err)
 	}
 }
 
@@ -173,7 +173,7 @@ func checkUpdate(lineState [][]byte, blockChannels []<-chan *protocol.Block) (nu
 		if !ok {
 			// Failed to drain the queue for this block
 			msg := "couldn't drain queue for %s"
-			logName(msg, conf.AllBlocks[index].Name)
+			logging.Log(fmt.Sprintf(msg, conf.AllBlocks[index].Name))
 
 			// Copy the error throttling block
 			// We set a name/instance for it below
@@ -190,7 +190,7 @@ func checkUpdate(lineState [][]byte, blockChannels []<-chan *protocol.Block) (nu
 
 		encodedBlock, err := json.Marshal(newBlock)
 		if err != nil {
-			logError("failed to marshal block", err)
+			logging.Error("failed to marshal block", err)
 			lineState[index] = []byte(conf.ErrorBlock)
 		} else {
 			lineState[index] = encodedBlock
@@ -226,17 +226,3 @@ func drainQueue(blockChan <-chan *protocol.Block) (*protocol.Block, bool) {
 
 	return newBlock, true
 }
-
-// Utility logging functions to write errors
-// to stderr
-func log(msg string) {
-	fmt.Fprintln(os.Stderr, msg)
-}
-
-func logError(msg string, err error) {
-	log(fmt.Sprintf(msg+" :: %v", err))
-}
-
-func logName(msg string, name string) {
-	log(fmt.Sprintf(msg, name))
-}
@@ -9,7 +9,15 @@ import (
 	"github.com/jameswelchman/clark/blocks/battery"
 	"github.com/jameswelchman/clark/blocks/clock"
 	"github.com/jameswelchman/clark/blocks/cpu"
+	"github.com/jameswelchman/clark/blocks/cpufreq"
+	"github.com/jameswelchman/clark/blocks/ipaddr"
+	"github.com/jameswelchman/clark/blocks/loadavg"
 	"github.com/jameswelchman/clark/blocks/memory"
+	"github.com/jameswelchman/clark/blocks/psi"
+	"github.com/jameswelchman/clark/blocks/swap"
+	"github.com/jameswelchman/clark/blocks/temperature"
+	"github.com/jameswelchman/clark/blocks/top"
+	"github.com/jameswelchman/clark/blocks/vpn"
 	"github.com/jameswelchman/clark/blocks/wifi"
 	"github.com/jameswelchman/clark/colors"
 	"github.com/jameswelchman/clark/protocol"
@@ -63,6 +71,10 @@ func NewBlock() *protocol.Block {
 // output to i3bar. Furthermore this where we define the order in which they
 // will appear in the final display. Adding and removing entries to this array
 // is required/sufficient to activate/deactive a particular block.
+// Blocks with settings export a Config type whose Run method may be used
+// in place of the package Run, for example
+//
+//	Run: temperature.Config{Sensor: "coretemp/Package id 0"}.Run
 var AllBlocks = [...]*blocks.Block{
 	&blocks.Block{
 		Name:     "clock",
@@ -74,16 +86,56 @@ var AllBlocks = [...]*blocks.Block{
 		Instance: "1",
 		Run:      wifi.Run,
 	},
+	&blocks.Block{
+		Name:     "ipaddr",
+		Instance: "1",
+		Run:      ipaddr.Run,
+	},
+	&blocks.Block{
+		Name:     "vpn",
+		Instance: "1",
+		Run:      vpn.Run,
+	},
 	&blocks.Block{
 		Name:     "memory",
 		Instance: "1",
 		Run:      memory.Run,
 	},
+	&blocks.Block{
+		Name:     "swap",
+		Instance: "1",
+		Run:      swap.Run,
+	},
+	&blocks.Block{
+		Name:     "psi",
+		Instance: "1",
+		Run:      psi.Run,
+	},
+	&blocks.Block{
+		Name:     "top",
+		Instance: "1",
+		Run:      top.Run,
+	},
 	&blocks.Block{
 		Name:     "cpu",
 		Instance: "1",
 		Run:      cpu.Run,
 	},
+	&blocks.Block{
+		Name:     "loadavg",
+		Instance: "1",
+		Run:      loadavg.Run,
+	},
+	&blocks.Block{
+		Name:     "cpufreq",
+		Instance: "1",
+		Run:      cpufreq.Run,
+	},
+	&blocks.Block{
+		Name:     "temperature",
+		Instance: "1",
+		Run:      temperature.Run,
+	},
 	&blocks.Block{
 		Name:     "battery",
 		Instance: "1",
@@ -0,0 +1,64 @@
+/*
+logging writes clark's errors to stderr, i3bar leaves stderr alone
+so they end up in the journal or the terminal clark was started from.
+
+Blocks use it for problems they carry on through, anything else
+should be returned from Run.
+*/
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// output is where Log writes, tests swap it for a buffer.
+var output io.Writer = os.Stderr
+
+// Log writes msg on its own line.
+func Log(msg string) {
+	fmt.Fprintln(output, msg)
+}
+
+// Error writes msg and err, e.g.
+// "couldn't load data usage :: permission denied".
+func Error(msg string, err error) {
+	Log(fmt.Sprintf("%s :: %v", msg, err))
+}
+
+// ErrorLog calls Error for problems which repeat every update,
+// such as a tool we aren't allowed to run. Each msg is only written
+// when its error changes, a nil error forgets it.
+//
+//	var errs logging.ErrorLog
+//	for range ticker.C {
+//		link, err := readLink()
+//		errs.Log("couldn't read link", err)
+//	}
+type ErrorLog struct {
+	mu   sync.Mutex
+	last map[string]string
+}
+
+// Log writes msg and err unless the last error for msg was the same.
+func (l *ErrorLog) Log(msg string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err == nil {
+		delete(l.last, msg)
+		return
+	}
+
+	if l.last == nil {
+		l.last = map[string]string{}
+	}
+	if last, ok := l.last[msg]; ok && last == err.Error() {
+		return
+	}
+
+	l.last[msg] = err.Error()
+	Error(msg, err)
+}
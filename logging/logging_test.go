@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestErrorLog(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer) { output = w }(output)
+	output = &buf
+
+	var l ErrorLog
+	denied := errors.New("permission denied")
+
+	l.Log("couldn't run wg", denied)
+	l.Log("couldn't run wg", denied)
+	l.Log("couldn't read link", denied)
+	if want := "couldn't run wg :: permission denied\ncouldn't read link :: permission denied\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	// A new error is written, and so is the old one once cleared
+	buf.Reset()
+	l.Log("couldn't run wg", errors.New("not found"))
+	l.Log("couldn't run wg", nil)
+	l.Log("couldn't run wg", nil)
+	l.Log("couldn't run wg", denied)
+	if want := "couldn't run wg :: not found\ncouldn't run wg :: permission denied\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestError(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer) { output = w }(output)
+	output = &buf
+
+	// msg isn't a format string, a stray % is written as is
+	Error("couldn't parse 100%", errors.New("bad"))
+	if want := "couldn't parse 100% :: bad\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -1,72 +1,184 @@
 /*
-bat implemnts functions for parsing the files in /sys/class/power_supply/BAT0
-It furthermore has some utility functions for doing percentage calculations.
+bat implements functions for parsing the files in /sys/class/power_supply.
+Every entry with type Battery is found, so machines with several batteries
+or none at all are handled. It furthermore has some utility functions for
+doing percentage calculations.
+
+	batteries, err := bat.GetBatteries()
+	if err == bat.ErrNoBattery {
+		// desktops have no battery
+	}
+	percent, err := bat.GetChargePercentage()
+
+GetStatus and GetChargePercentage combine every battery into one reading.
+Batteries report charge (µAh), energy (µWh) or only a capacity percentage,
+see Reading for how these are normalised. Info holds the design capacity
+and cycle count, and GetAdapters reads the Mains and USB power supplies.
 */
 package bat
 
 import (
-	"io/ioutil"
-	"strconv"
-	"strings"
+	"errors"
+	"path/filepath"
+
+	"github.com/jameswelchman/clark/pkg/sysfs"
 )
 
-const filePath = "/sys/class/power_supply/BAT0"
+const filePath = "/sys/class/power_supply"
 
-// GetStatus will read the status file.
-// Possible returns are "Charging", "Discharging" and "Unknown"
-// All file read errors are returned
-func GetStatus() (string, error) {
-	status, err := ioutil.ReadFile(filePath + "/status")
+// ErrNoBattery is returned when there is no battery in power_supply.
+var ErrNoBattery = errors.New("no battery found")
+
+// Battery is a single power_supply entry with type Battery.
+type Battery struct {
+	// Name is the directory name, such as BAT0
+	Name string
+	path string
+}
+
+// GetBatteries returns every power_supply entry with type Battery.
+// Batteries with scope Device belong to peripherals such as mice
+// and are skipped. ErrNoBattery is returned when none are found.
+func GetBatteries() ([]*Battery, error) {
+	return findBatteries(filePath)
+}
+
+func findBatteries(dir string) ([]*Battery, error) {
+	paths, err := filepath.Glob(dir + "/*")
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return strings.TrimSuffix(string(status[:]), "\n"), nil
+
+	var batteries []*Battery
+	for _, p := range paths {
+		supplyType, err := sysfs.ReadString(p + "/type")
+		if err != nil || supplyType != "Battery" {
+			continue
+		}
+
+		if scope, err := sysfs.ReadString(p + "/scope"); err == nil && scope == "Device" {
+			continue
+		}
+
+		batteries = append(batteries, &Battery{
+			Name: filepath.Base(p),
+			path: p,
+		})
+	}
+
+	if len(batteries) == 0 {
+		return nil, ErrNoBattery
+	}
+
+	return batteries, nil
 }
 
-func parseFloat(p []byte) (float64, error) {
-	amount := strings.TrimSuffix(string(p[:]), "\n")
+// Status will read the status file.
+// Possible returns are "Charging", "Discharging", "Not charging",
+// "Full" and "Unknown". All file read errors are returned.
+func (b *Battery) Status() (string, error) {
+	return sysfs.ReadString(b.path + "/status")
+}
+
+// Read will read the charge of the battery. See Reading for the
+// attributes which are tried.
+func (b *Battery) Read() (*Reading, error) {
+	return readBattery(b.path)
+}
 
-	num, err := strconv.ParseFloat(amount, 64)
+// ChargePercentage will get the current
+// charge percentage - as a float64
+func (b *Battery) ChargePercentage() (float64, error) {
+	reading, err := b.Read()
 	if err != nil {
 		return 0, err
 	}
 
-	return num, nil
+	return reading.Percentage(), nil
 }
 
-func parseFloatFile(filePath string) (float64, error) {
-	raw, err := ioutil.ReadFile(filePath)
+// GetStatus will read the status of every battery and combine them.
+// If any battery is charging we are "Charging", otherwise if any is
+// discharging we are "Discharging". Failing that the first status
+// is returned.
+func GetStatus() (string, error) {
+	batteries, err := GetBatteries()
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	var statuses []string
+	for _, b := range batteries {
+		status, err := b.Status()
+		if err != nil {
+			return "", err
+		}
+		statuses = append(statuses, status)
 	}
-	return parseFloat(raw)
+
+	return combineStatus(statuses), nil
 }
 
-// GetFullCharge will return the number given for
-// full charge - as a float64
-func GetFullCharge() (float64, error) {
-	return parseFloatFile(filePath + "/charge_full")
+func combineStatus(statuses []string) string {
+	for _, want := range []string{"Charging", "Discharging"} {
+		for _, s := range statuses {
+			if s == want {
+				return s
+			}
+		}
+	}
 
+	return statuses[0]
 }
 
-// GetCurrentcharge will return the number given for
-// current charge - as a float64
-func GetCurrentCharge() (float64, error) {
-	return parseFloatFile(filePath + "/charge_now")
+// GetReading will read every battery and combine them with Combine.
+func GetReading() (*Reading, error) {
+	batteries, err := GetBatteries()
+	if err != nil {
+		return nil, err
+	}
+
+	var readings []*Reading
+	for _, b := range batteries {
+		reading, err := b.Read()
+		if err != nil {
+			return nil, err
+		}
+		readings = append(readings, reading)
+	}
+
+	return Combine(readings), nil
 }
 
-// GetChargePercentage will get the current
-// charge percentage - as a float64
-func GetChargePercentage() (float64, error) {
-	full, err := GetFullCharge()
+// GetFullCharge will return the full charge of every battery
+// added together in µAh - as a float64. It is zero for batteries
+// which only report energy without a voltage.
+func GetFullCharge() (float64, error) {
+	reading, err := GetReading()
 	if err != nil {
 		return 0, err
 	}
+	return reading.ChargeFull, nil
+}
 
-	current, err := GetCurrentCharge()
+// GetCurrentCharge will return the current charge of every battery
+// added together in µAh - as a float64. It is zero for batteries
+// which only report energy without a voltage.
+func GetCurrentCharge() (float64, error) {
+	reading, err := GetReading()
 	if err != nil {
 		return 0, err
 	}
+	return reading.ChargeNow, nil
+}
 
-	return (current / full) * 100, nil
+// GetChargePercentage will get the current charge percentage
+// over every battery - as a float64. A larger battery counts
+// for more than a smaller one.
+func GetChargePercentage() (float64, error) {
+	reading, err := GetReading()
+	if err != nil {
+		return 0, err
+	}
+	return reading.Percentage(), nil
 }
@@ -0,0 +1,78 @@
+package bat
+
+import (
+	"math"
+	"time"
+)
+
+// smoothing is the time constant of the average in Estimator.
+// Longer gives a steadier estimate which is slower to react.
+const smoothing = 5 * time.Minute
+
+// Estimator estimates the time remaining from a history of charge
+// percentages, for batteries which report neither power_now nor
+// current_now. For the first few minutes we use the average rate
+// since we started (dis)charging, after that an exponentially weighted
+// average. Everything is reset whenever we start or stop charging.
+//
+//	var e bat.Estimator
+//	// once per sample
+//	e.Add(reading.Percentage(), charging, time.Now())
+//	remaining := e.Remaining(reading.Percentage())
+type Estimator struct {
+	// rate is in percent per second, negative when discharging
+	rate     float64
+	charging bool
+
+	// The first and latest samples since the last reset
+	startPercent float64
+	start        time.Time
+	percent      float64
+	when         time.Time
+}
+
+// Add records a sample of the charge percentage.
+func (e *Estimator) Add(percent float64, charging bool, now time.Time) {
+	if e.start.IsZero() || charging != e.charging {
+		*e = Estimator{
+			charging:     charging,
+			startPercent: percent,
+			start:        now,
+			percent:      percent,
+			when:         now,
+		}
+		return
+	}
+
+	dt := now.Sub(e.when).Seconds()
+	if dt <= 0 {
+		return
+	}
+
+	// The percentage moves in steps, so a short history is noisy
+	if elapsed := now.Sub(e.start); elapsed < smoothing {
+		e.rate = (percent - e.startPercent) / elapsed.Seconds()
+	} else {
+		alpha := 1 - math.Exp(-dt/smoothing.Seconds())
+		e.rate += alpha * ((percent-e.percent)/dt - e.rate)
+	}
+
+	e.percent = percent
+	e.when = now
+}
+
+// Remaining returns the time until empty when discharging, or until
+// full when charging. It is zero until the rate is known.
+func (e *Estimator) Remaining(percent float64) time.Duration {
+	var seconds float64
+	switch {
+	case e.charging && e.rate > 0:
+		seconds = (100 - percent) / e.rate
+	case !e.charging && e.rate < 0:
+		seconds = percent / -e.rate
+	default:
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,178 @@
+package bat
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/jameswelchman/clark/pkg/sysfs"
+)
+
+// Info holds the attributes of a battery which rarely change.
+// Fields the battery doesn't report are left empty or zero.
+type Info struct {
+	// ChargeFullDesign is in µAh and EnergyFullDesign in µWh
+	ChargeFullDesign float64
+	EnergyFullDesign float64
+
+	CycleCount   int
+	Technology   string
+	Manufacturer string
+	ModelName    string
+}
+
+// Adapter is a power_supply entry with type Mains or USB.
+type Adapter struct {
+	Name   string
+	Type   string
+	Online bool
+}
+
+// Info will read the design capacity, cycle count
+// and the descriptive strings of the battery.
+func (b *Battery) Info() (*Info, error) {
+	return readInfo(b.path)
+}
+
+func readInfo(dir string) (*Info, error) {
+	info := &Info{}
+
+	var err error
+	info.ChargeFullDesign, _, err = readOptional(dir + "/charge_full_design")
+	if err != nil {
+		return nil, err
+	}
+
+	info.EnergyFullDesign, _, err = readOptional(dir + "/energy_full_design")
+	if err != nil {
+		return nil, err
+	}
+
+	cycles, _, err := readOptional(dir + "/cycle_count")
+	if err != nil {
+		return nil, err
+	}
+	info.CycleCount = int(cycles)
+
+	// These are only for display so missing files are fine
+	info.Technology, _ = sysfs.ReadString(dir + "/technology")
+	info.Manufacturer, _ = sysfs.ReadString(dir + "/manufacturer")
+	info.ModelName, _ = sysfs.ReadString(dir + "/model_name")
+
+	return info, nil
+}
+
+// Health returns the full capacity in r as a percentage of the
+// design capacity. It is zero when the design capacity is unknown.
+func (i *Info) Health(r *Reading) float64 {
+	if i.EnergyFullDesign > 0 && r.EnergyFull > 0 {
+		return r.EnergyFull / i.EnergyFullDesign * 100
+	}
+
+	if i.ChargeFullDesign > 0 && r.ChargeFull > 0 {
+		return r.ChargeFull / i.ChargeFullDesign * 100
+	}
+
+	return 0
+}
+
+// CombineInfo adds design capacities from several batteries, to be
+// used with a Reading from Combine. A design capacity is only kept
+// when every battery reports it. The cycle count is the highest of
+// them and the strings are taken from the first battery.
+func CombineInfo(infos []*Info) *Info {
+	if len(infos) == 1 {
+		return infos[0]
+	}
+
+	combined := Info(*infos[0])
+	combined.ChargeFullDesign, combined.EnergyFullDesign = 0, 0
+
+	hasCharge, hasEnergy := true, true
+	for _, i := range infos {
+		hasCharge = hasCharge && i.ChargeFullDesign > 0
+		hasEnergy = hasEnergy && i.EnergyFullDesign > 0
+
+		combined.ChargeFullDesign += i.ChargeFullDesign
+		combined.EnergyFullDesign += i.EnergyFullDesign
+		if i.CycleCount > combined.CycleCount {
+			combined.CycleCount = i.CycleCount
+		}
+	}
+
+	if !hasCharge {
+		combined.ChargeFullDesign = 0
+	}
+	if !hasEnergy {
+		combined.EnergyFullDesign = 0
+	}
+
+	return &combined
+}
+
+// GetInfo will read every battery and combine them with CombineInfo.
+func GetInfo() (*Info, error) {
+	batteries, err := GetBatteries()
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []*Info
+	for _, b := range batteries {
+		info, err := b.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+
+	return CombineInfo(infos), nil
+}
+
+// GetAdapters returns every power_supply entry with type Mains
+// or one of the USB types, such as AC or ucsi-source-psy-USBC000:001.
+func GetAdapters() ([]*Adapter, error) {
+	paths, err := filepath.Glob(filePath + "/*")
+	if err != nil {
+		return nil, err
+	}
+
+	var adapters []*Adapter
+	for _, p := range paths {
+		supplyType, err := sysfs.ReadString(p + "/type")
+		if err != nil {
+			continue
+		}
+		if supplyType != "Mains" && !strings.HasPrefix(supplyType, "USB") {
+			continue
+		}
+
+		online, err := sysfs.ReadString(p + "/online")
+		if err != nil {
+			continue
+		}
+
+		adapters = append(adapters, &Adapter{
+			Name:   filepath.Base(p),
+			Type:   supplyType,
+			Online: online != "0",
+		})
+	}
+
+	return adapters, nil
+}
+
+// GetPluggedIn reports whether any adapter is online.
+func GetPluggedIn() (bool, error) {
+	adapters, err := GetAdapters()
+	if err != nil {
+		return false, err
+	}
+
+	for _, a := range adapters {
+		if a.Online {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
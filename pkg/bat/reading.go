@@ -0,0 +1,284 @@
+package bat
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/jameswelchman/clark/pkg/sysfs"
+)
+
+// The attribute sets a Reading may be taken from
+const (
+	SourceEnergy   = "energy"
+	SourceCharge   = "charge"
+	SourceCapacity = "capacity"
+)
+
+// Reading is a single read of the charge of a battery.
+//
+// The attributes are tried in a fixed order and the first complete
+// set is used:
+//
+//  1. energy_now and energy_full (µWh)
+//  2. charge_now and charge_full (µAh)
+//  3. capacity (percent)
+//
+// Source records which set was found. When the battery reports a
+// voltage the other of charge and energy is computed from it, using
+// voltage_min_design if present and voltage_now otherwise. Fields
+// which can't be known are left as zero.
+type Reading struct {
+	Source string
+
+	ChargeNow  float64
+	ChargeFull float64
+	EnergyNow  float64
+	EnergyFull float64
+
+	// Voltage is voltage_now in µV
+	Voltage float64
+
+	// PowerNow (µW) and CurrentNow (µA) are the rate of charge or
+	// discharge. When only one is reported the other is computed
+	// from the voltage. Both are zero when the hardware reports neither.
+	PowerNow   float64
+	CurrentNow float64
+
+	// Capacity is the capacity file, or computed from the
+	// other fields when the battery doesn't provide one.
+	Capacity float64
+}
+
+// Percentage returns the charge as a percentage of full.
+func (r *Reading) Percentage() float64 {
+	if r.EnergyFull > 0 {
+		return r.EnergyNow / r.EnergyFull * 100
+	}
+
+	if r.ChargeFull > 0 {
+		return r.ChargeNow / r.ChargeFull * 100
+	}
+
+	return r.Capacity
+}
+
+// TimeToEmpty returns how long the battery lasts at the current rate.
+// It is zero when the hardware doesn't report a rate, see Estimator.
+func (r *Reading) TimeToEmpty() time.Duration {
+	if r.EnergyNow > 0 && r.PowerNow > 0 {
+		return hours(r.EnergyNow / r.PowerNow)
+	}
+
+	if r.ChargeNow > 0 && r.CurrentNow > 0 {
+		return hours(r.ChargeNow / r.CurrentNow)
+	}
+
+	return 0
+}
+
+// TimeToFull returns how long until the battery is full at the current
+// rate. It is zero when the hardware doesn't report a rate.
+func (r *Reading) TimeToFull() time.Duration {
+	if r.EnergyFull > 0 && r.PowerNow > 0 {
+		return hours((r.EnergyFull - r.EnergyNow) / r.PowerNow)
+	}
+
+	if r.ChargeFull > 0 && r.CurrentNow > 0 {
+		return hours((r.ChargeFull - r.ChargeNow) / r.CurrentNow)
+	}
+
+	return 0
+}
+
+func hours(h float64) time.Duration {
+	if h < 0 {
+		return 0
+	}
+	return time.Duration(h * float64(time.Hour))
+}
+
+// readOptional reads a float file, returning zero and false when the
+// attribute isn't there. Other errors are returned.
+func readOptional(filePath string) (float64, bool, error) {
+	val, err := sysfs.ReadFloat(filePath)
+	if isAbsent(err) {
+		return 0, false, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+	return val, true, nil
+}
+
+// isAbsent returns true when reading an attribute failed because the
+// hardware doesn't report it. Besides missing files, many ACPI
+// batteries fail the read with ENODEV, ENODATA or EIO, for example
+// when the battery is detached or the EC doesn't know the value.
+func isAbsent(err error) bool {
+	if err == nil {
+		return false
+	}
+	if os.IsNotExist(err) {
+		return true
+	}
+
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Err
+	}
+	switch err {
+	case syscall.ENODEV, syscall.ENODATA, syscall.EIO:
+		return true
+	}
+	return false
+}
+
+// readPair reads the _now and _full files of prefix (energy or charge).
+func readPair(dir, prefix string) (now, full float64, ok bool, err error) {
+	now, okNow, err := readOptional(dir + "/" + prefix + "_now")
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	full, okFull, err := readOptional(dir + "/" + prefix + "_full")
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	return now, full, okNow && okFull && full > 0, nil
+}
+
+// readBattery reads the power_supply directory of a single battery.
+func readBattery(dir string) (*Reading, error) {
+	r := &Reading{}
+
+	voltage, _, err := readOptional(dir + "/voltage_now")
+	if err != nil {
+		return nil, err
+	}
+	r.Voltage = voltage
+
+	// The nominal voltage gives a better conversion than
+	// the instantaneous one, which sags under load.
+	design, ok, err := readOptional(dir + "/voltage_min_design")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		design = voltage
+	}
+
+	// Some drivers report a negative rate while discharging
+	power, _, err := readOptional(dir + "/power_now")
+	if err != nil {
+		return nil, err
+	}
+	current, _, err := readOptional(dir + "/current_now")
+	if err != nil {
+		return nil, err
+	}
+	r.PowerNow, r.CurrentNow = math.Abs(power), math.Abs(current)
+	if r.PowerNow == 0 && voltage > 0 {
+		r.PowerNow = r.CurrentNow * voltage / 1e6
+	}
+	if r.CurrentNow == 0 && voltage > 0 {
+		r.CurrentNow = r.PowerNow / voltage * 1e6
+	}
+
+	capacity, hasCapacity, err := readOptional(dir + "/capacity")
+	if err != nil {
+		return nil, err
+	}
+
+	now, full, ok, err := readPair(dir, "energy")
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		r.Source = SourceEnergy
+		r.EnergyNow, r.EnergyFull = now, full
+		if design > 0 {
+			// µWh / µV = Ah, so scale back up to µAh
+			r.ChargeNow = now / design * 1e6
+			r.ChargeFull = full / design * 1e6
+		}
+	} else {
+		now, full, ok, err = readPair(dir, "charge")
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			r.Source = SourceCharge
+			r.ChargeNow, r.ChargeFull = now, full
+			if design > 0 {
+				r.EnergyNow = now * design / 1e6
+				r.EnergyFull = full * design / 1e6
+			}
+		} else if hasCapacity {
+			r.Source = SourceCapacity
+		} else {
+			return nil, fmt.Errorf("no energy, charge or capacity in %s", dir)
+		}
+	}
+
+	r.Capacity = capacity
+	if !hasCapacity {
+		r.Capacity = r.Percentage()
+	}
+
+	return r, nil
+}
+
+// Combine adds readings from several batteries into one. Energy is
+// summed when every battery has it, then charge, and capacity is
+// averaged as a last resort. A single reading is returned as it is.
+func Combine(readings []*Reading) *Reading {
+	if len(readings) == 1 {
+		return readings[0]
+	}
+
+	combined := &Reading{Source: SourceEnergy}
+	for _, r := range readings {
+		if r.EnergyFull == 0 && combined.Source == SourceEnergy {
+			combined.Source = SourceCharge
+		}
+	}
+	for _, r := range readings {
+		if r.ChargeFull == 0 && combined.Source == SourceCharge {
+			combined.Source = SourceCapacity
+		}
+	}
+
+	for _, r := range readings {
+		switch combined.Source {
+		case SourceEnergy:
+			combined.EnergyNow += r.EnergyNow
+			combined.EnergyFull += r.EnergyFull
+			combined.ChargeNow += r.ChargeNow
+			combined.ChargeFull += r.ChargeFull
+		case SourceCharge:
+			combined.ChargeNow += r.ChargeNow
+			combined.ChargeFull += r.ChargeFull
+		}
+		combined.Capacity += r.Capacity / float64(len(readings))
+		combined.PowerNow += r.PowerNow
+		combined.CurrentNow += r.CurrentNow
+	}
+
+	// Charge can only be summed if every battery has it
+	if combined.Source == SourceEnergy {
+		for _, r := range readings {
+			if r.ChargeFull == 0 {
+				combined.ChargeNow, combined.ChargeFull = 0, 0
+				break
+			}
+		}
+	}
+
+	if combined.Source != SourceCapacity {
+		combined.Capacity = combined.Percentage()
+	}
+
+	return combined
+}
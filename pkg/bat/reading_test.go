@@ -0,0 +1,320 @@
+package bat
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// writeSupply creates a power_supply directory holding attrs.
+func writeSupply(t *testing.T, attrs map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, value := range attrs {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6*math.Max(1, math.Abs(b))
+}
+
+func TestReadBattery(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs map[string]string
+		want  Reading
+	}{
+		{
+			name: "energy with voltage_min_design",
+			attrs: map[string]string{
+				"energy_now":         "30000000",
+				"energy_full":        "60000000",
+				"voltage_now":        "12000000",
+				"voltage_min_design": "10000000",
+				"power_now":          "6000000",
+			},
+			want: Reading{
+				Source:     SourceEnergy,
+				EnergyNow:  30e6,
+				EnergyFull: 60e6,
+				ChargeNow:  3e6,
+				ChargeFull: 6e6,
+				Voltage:    12e6,
+				PowerNow:   6e6,
+				CurrentNow: 0.5e6,
+				Capacity:   50,
+			},
+		},
+		{
+			name: "energy with only voltage_now",
+			attrs: map[string]string{
+				"energy_now":  "30000000",
+				"energy_full": "60000000",
+				"voltage_now": "15000000",
+			},
+			want: Reading{
+				Source:     SourceEnergy,
+				EnergyNow:  30e6,
+				EnergyFull: 60e6,
+				ChargeNow:  2e6,
+				ChargeFull: 4e6,
+				Voltage:    15e6,
+				Capacity:   50,
+			},
+		},
+		{
+			name: "energy without voltage",
+			attrs: map[string]string{
+				"energy_now":  "15000000",
+				"energy_full": "60000000",
+				"power_now":   "-5000000",
+			},
+			want: Reading{
+				Source:     SourceEnergy,
+				EnergyNow:  15e6,
+				EnergyFull: 60e6,
+				PowerNow:   5e6,
+				Capacity:   25,
+			},
+		},
+		{
+			name: "charge with voltage_min_design",
+			attrs: map[string]string{
+				"charge_now":         "2000000",
+				"charge_full":        "4000000",
+				"voltage_now":        "12000000",
+				"voltage_min_design": "11000000",
+				"current_now":        "1000000",
+			},
+			want: Reading{
+				Source:     SourceCharge,
+				ChargeNow:  2e6,
+				ChargeFull: 4e6,
+				EnergyNow:  22e6,
+				EnergyFull: 44e6,
+				Voltage:    12e6,
+				CurrentNow: 1e6,
+				PowerNow:   12e6,
+				Capacity:   50,
+			},
+		},
+		{
+			name: "charge without voltage",
+			attrs: map[string]string{
+				"charge_now":  "1000000",
+				"charge_full": "4000000",
+			},
+			want: Reading{
+				Source:     SourceCharge,
+				ChargeNow:  1e6,
+				ChargeFull: 4e6,
+				Capacity:   25,
+			},
+		},
+		{
+			name:  "capacity only",
+			attrs: map[string]string{"capacity": "42"},
+			want: Reading{
+				Source:   SourceCapacity,
+				Capacity: 42,
+			},
+		},
+		{
+			name: "energy is preferred over charge and capacity",
+			attrs: map[string]string{
+				"energy_now":  "10000000",
+				"energy_full": "40000000",
+				"charge_now":  "3000000",
+				"charge_full": "4000000",
+				"capacity":    "26",
+			},
+			want: Reading{
+				Source:     SourceEnergy,
+				EnergyNow:  10e6,
+				EnergyFull: 40e6,
+				Capacity:   26,
+			},
+		},
+		{
+			name: "incomplete energy falls back to charge",
+			attrs: map[string]string{
+				"energy_now":  "10000000",
+				"charge_now":  "3000000",
+				"charge_full": "4000000",
+			},
+			want: Reading{
+				Source:     SourceCharge,
+				ChargeNow:  3e6,
+				ChargeFull: 4e6,
+				Capacity:   75,
+			},
+		},
+		{
+			name: "zero charge_full falls back to capacity",
+			attrs: map[string]string{
+				"charge_now":  "3000000",
+				"charge_full": "0",
+				"capacity":    "80",
+			},
+			want: Reading{
+				Source:   SourceCapacity,
+				Capacity: 80,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readBattery(writeSupply(t, tt.attrs))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Source != tt.want.Source ||
+				!closeTo(got.EnergyNow, tt.want.EnergyNow) ||
+				!closeTo(got.EnergyFull, tt.want.EnergyFull) ||
+				!closeTo(got.ChargeNow, tt.want.ChargeNow) ||
+				!closeTo(got.ChargeFull, tt.want.ChargeFull) ||
+				!closeTo(got.Voltage, tt.want.Voltage) ||
+				!closeTo(got.PowerNow, tt.want.PowerNow) ||
+				!closeTo(got.CurrentNow, tt.want.CurrentNow) ||
+				!closeTo(got.Capacity, tt.want.Capacity) {
+				t.Errorf("got %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadBatteryNothing(t *testing.T) {
+	_, err := readBattery(writeSupply(t, map[string]string{"voltage_now": "12000000"}))
+	if err == nil {
+		t.Error("expected an error with no energy, charge or capacity")
+	}
+}
+
+func TestCombine(t *testing.T) {
+	energy := func(now, full float64) *Reading {
+		return &Reading{
+			Source:     SourceEnergy,
+			EnergyNow:  now,
+			EnergyFull: full,
+			PowerNow:   1e6,
+			Capacity:   now / full * 100,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		bat0, bat1 *Reading
+		source     string
+		percent    float64
+		energyFull float64
+		chargeFull float64
+	}{
+		{
+			name:       "both energy",
+			bat0:       energy(20e6, 40e6),
+			bat1:       energy(10e6, 20e6),
+			source:     SourceEnergy,
+			percent:    50,
+			energyFull: 60e6,
+		},
+		{
+			name: "energy and charge",
+			bat0: energy(40e6, 40e6),
+			bat1: &Reading{
+				Source:     SourceCharge,
+				ChargeNow:  0,
+				ChargeFull: 2e6,
+				Capacity:   0,
+			},
+			source:     SourceCapacity,
+			percent:    50,
+			energyFull: 0,
+		},
+		{
+			name:       "both charge",
+			bat0:       &Reading{Source: SourceCharge, ChargeNow: 1e6, ChargeFull: 2e6, Capacity: 50},
+			bat1:       &Reading{Source: SourceCharge, ChargeNow: 2e6, ChargeFull: 2e6, Capacity: 100},
+			source:     SourceCharge,
+			percent:    75,
+			chargeFull: 4e6,
+		},
+		{
+			name:    "energy and capacity",
+			bat0:    energy(30e6, 40e6),
+			bat1:    &Reading{Source: SourceCapacity, Capacity: 25},
+			source:  SourceCapacity,
+			percent: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Combine([]*Reading{tt.bat0, tt.bat1})
+			if got.Source != tt.source {
+				t.Errorf("source %q, want %q", got.Source, tt.source)
+			}
+			if !closeTo(got.Percentage(), tt.percent) {
+				t.Errorf("percentage %v, want %v", got.Percentage(), tt.percent)
+			}
+			if !closeTo(got.EnergyFull, tt.energyFull) {
+				t.Errorf("energy_full %v, want %v", got.EnergyFull, tt.energyFull)
+			}
+			if !closeTo(got.ChargeFull, tt.chargeFull) {
+				t.Errorf("charge_full %v, want %v", got.ChargeFull, tt.chargeFull)
+			}
+			if !closeTo(got.PowerNow, tt.bat0.PowerNow+tt.bat1.PowerNow) {
+				t.Errorf("power_now %v, want the sum", got.PowerNow)
+			}
+		})
+	}
+}
+
+func TestCombineSingle(t *testing.T) {
+	r := &Reading{Source: SourceCapacity, Capacity: 42}
+	if got := Combine([]*Reading{r}); got != r {
+		t.Errorf("a single reading should be returned as it is, got %+v", got)
+	}
+}
+
+func TestReadOptional(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "power_now"), []byte("12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	val, ok, err := readOptional(filepath.Join(dir, "power_now"))
+	if err != nil || !ok || val != 12 {
+		t.Errorf("got %v, %v, %v, want 12, true, nil", val, ok, err)
+	}
+
+	val, ok, err = readOptional(filepath.Join(dir, "current_now"))
+	if err != nil || ok || val != 0 {
+		t.Errorf("got %v, %v, %v for a missing file, want 0, false, nil", val, ok, err)
+	}
+}
+
+func TestIsAbsent(t *testing.T) {
+	for _, errno := range []syscall.Errno{syscall.ENOENT, syscall.ENODEV, syscall.ENODATA, syscall.EIO} {
+		if !isAbsent(&os.PathError{Op: "read", Path: "power_now", Err: errno}) {
+			t.Errorf("%v should count as absent", errno)
+		}
+	}
+
+	if isAbsent(&os.PathError{Op: "open", Path: "power_now", Err: syscall.EACCES}) {
+		t.Error("EACCES should not count as absent")
+	}
+	if isAbsent(nil) {
+		t.Error("nil should not count as absent")
+	}
+}
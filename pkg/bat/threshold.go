@@ -0,0 +1,117 @@
+package bat
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// ErrNoThreshold is returned for batteries without charge_control_end_threshold.
+var ErrNoThreshold = errors.New("charge thresholds not supported")
+
+// Threshold holds the charge control thresholds in percent.
+// Charging starts below Start and stops at End. Start is zero
+// for batteries which only support an end threshold.
+type Threshold struct {
+	Start int
+	End   int
+}
+
+// Threshold will read the charge control thresholds.
+func (b *Battery) Threshold() (*Threshold, error) {
+	end, ok, err := readOptional(b.path + "/charge_control_end_threshold")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNoThreshold
+	}
+
+	start, _, err := readOptional(b.path + "/charge_control_start_threshold")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Threshold{Start: int(start), End: int(end)}, nil
+}
+
+// SetThreshold will write the charge control thresholds. The kernel
+// rejects a start above the end, so the order of the writes depends
+// on whether the thresholds are moving up or down. Writing usually
+// requires root, the caller may check errors with os.IsPermission.
+func (b *Battery) SetThreshold(t Threshold) error {
+	current, err := b.Threshold()
+	if err != nil {
+		return err
+	}
+
+	writeStart := func() error {
+		// Batteries without a start threshold only take the end
+		if _, err := os.Stat(b.path + "/charge_control_start_threshold"); os.IsNotExist(err) {
+			return nil
+		}
+		return writeInt(b.path+"/charge_control_start_threshold", t.Start)
+	}
+	writeEnd := func() error {
+		return writeInt(b.path+"/charge_control_end_threshold", t.End)
+	}
+
+	first, second := writeEnd, writeStart
+	if t.Start < current.End {
+		first, second = writeStart, writeEnd
+	}
+
+	if err := first(); err != nil {
+		return err
+	}
+	return second()
+}
+
+func writeInt(filePath string, value int) error {
+	return ioutil.WriteFile(filePath, []byte(strconv.Itoa(value)), 0644)
+}
+
+// GetThreshold returns the thresholds of the first battery which
+// supports them, or ErrNoThreshold.
+func GetThreshold() (*Threshold, error) {
+	batteries, err := GetBatteries()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, b := range batteries {
+		t, err := b.Threshold()
+		if err == ErrNoThreshold {
+			continue
+		}
+		return t, err
+	}
+
+	return nil, ErrNoThreshold
+}
+
+// SetThresholds writes t to every battery which supports thresholds.
+// We stop at the first error.
+func SetThresholds(t Threshold) error {
+	batteries, err := GetBatteries()
+	if err != nil {
+		return err
+	}
+
+	found := false
+	for _, b := range batteries {
+		err := b.SetThreshold(t)
+		if err == ErrNoThreshold {
+			continue
+		} else if err != nil {
+			return err
+		}
+		found = true
+	}
+
+	if !found {
+		return ErrNoThreshold
+	}
+	return nil
+}
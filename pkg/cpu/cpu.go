@@ -65,6 +65,19 @@ func (c *Client) GetLoads() ([]float64, error) {
 	return loads, nil
 }
 
+// NumCpus returns the number of individual cpus found in /proc/stat
+// when the client was created. The cpu total line is not counted.
+func (c *Client) NumCpus() int {
+	numCpus := 0
+	for _, r := range c.cpuReads {
+		if r.cpu != "cpu" {
+			numCpus++
+		}
+	}
+
+	return numCpus
+}
+
 // NewClient creates a new instance of the client
 func NewClient() (*Client, error) {
 	cpuReads, err := buildAllCpuTimeStats()
@@ -0,0 +1,144 @@
+/*
+cpufreq implements functions for reading and writing the files in
+/sys/devices/system/cpu/cpuN/cpufreq. These hold the current frequency,
+the scaling governor and the energy performance preference of each cpu.
+
+	stats, err := cpufreq.GetStats()
+	// .. handle error
+	fmt.Println(stats.AvgFreq, stats.Governor)
+
+Frequencies are float64 and the units are kHz.
+Writing the governor or preference usually requires root.
+*/
+package cpufreq
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const filePath = "/sys/devices/system/cpu"
+
+// ErrNoCpufreq is returned when no cpu exposes a cpufreq directory.
+// This is common inside virtual machines.
+var ErrNoCpufreq = errors.New("no cpufreq directories found")
+
+// Stats is the combined reading over all cpus.
+type Stats struct {
+	// AvgFreq and MaxFreq are taken from scaling_cur_freq.
+	AvgFreq float64
+	MaxFreq float64
+
+	// Governor and Epp are read from the first cpu.
+	// Epp is empty when energy_performance_preference doesn't exist.
+	Governor string
+	Epp      string
+
+	// Governors and Epps are the values which may be written.
+	Governors []string
+	Epps      []string
+}
+
+// cpufreqDirs returns the cpufreq directory of every cpu.
+func cpufreqDirs() ([]string, error) {
+	dirs, err := filepath.Glob(filePath + "/cpu[0-9]*/cpufreq")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dirs) == 0 {
+		return nil, ErrNoCpufreq
+	}
+
+	return dirs, nil
+}
+
+func readString(filePath string) (string, error) {
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+func readFloat(filePath string) (float64, error) {
+	raw, err := readString(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// GetStats will read the cpufreq directory of every cpu.
+// All file read errors are returned except for the optional
+// energy performance files, which only some drivers provide.
+func GetStats() (*Stats, error) {
+	dirs, err := cpufreqDirs()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+	for _, dir := range dirs {
+		freq, err := readFloat(dir + "/scaling_cur_freq")
+		if err != nil {
+			return nil, err
+		}
+
+		stats.AvgFreq += freq
+		if freq > stats.MaxFreq {
+			stats.MaxFreq = freq
+		}
+	}
+	stats.AvgFreq /= float64(len(dirs))
+
+	stats.Governor, err = readString(dirs[0] + "/scaling_governor")
+	if err != nil {
+		return nil, err
+	}
+
+	governors, err := readString(dirs[0] + "/scaling_available_governors")
+	if err != nil {
+		return nil, err
+	}
+	stats.Governors = strings.Fields(governors)
+
+	// The preference files don't exist for every driver
+	stats.Epp, _ = readString(dirs[0] + "/energy_performance_preference")
+	epps, _ := readString(dirs[0] + "/energy_performance_available_preferences")
+	stats.Epps = strings.Fields(epps)
+
+	return stats, nil
+}
+
+// writeAll writes value to the named file in every cpufreq directory.
+// We stop at the first error, which the caller may check
+// with os.IsPermission.
+func writeAll(name, value string) error {
+	dirs, err := cpufreqDirs()
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		err = ioutil.WriteFile(dir+"/"+name, []byte(value), 0644)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SetGovernor writes the scaling governor of every cpu.
+func SetGovernor(governor string) error {
+	return writeAll("scaling_governor", governor)
+}
+
+// SetEpp writes the energy performance preference of every cpu.
+func SetEpp(epp string) error {
+	return writeAll("energy_performance_preference", epp)
+}
@@ -0,0 +1,240 @@
+/*
+hwmon implements functions for reading temperature and fan sensors.
+Sensors are discovered in /sys/class/hwmon and /sys/class/thermal.
+
+	sensors, err := hwmon.GetSensors()
+	// .. handle error
+	hottest := hwmon.Hottest(sensors)
+	fmt.Println(hottest.Name(), hottest.Temp)
+
+Temperatures are float64 and the units are degrees Celsius.
+Fan speeds are float64 and the units are RPM.
+*/
+package hwmon
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/jameswelchman/clark/pkg/sysfs"
+)
+
+const (
+	hwmonPath   = "/sys/class/hwmon"
+	thermalPath = "/sys/class/thermal"
+)
+
+// ErrNoSensors is returned when no temperature sensor could be found.
+var ErrNoSensors = errors.New("no temperature sensors found")
+
+type (
+	// Sensor is a single temperature reading.
+	// Crit and Max are zero when the hardware doesn't provide them.
+	Sensor struct {
+		Chip  string
+		Label string
+		Temp  float64
+		Crit  float64
+		Max   float64
+	}
+
+	// Fan is a single fan speed reading.
+	Fan struct {
+		Chip  string
+		Label string
+		Rpm   float64
+	}
+)
+
+// Name returns "chip/label" which identifies a sensor.
+func (s *Sensor) Name() string {
+	return s.Chip + "/" + s.Label
+}
+
+// Name returns "chip/label" which identifies a fan.
+func (f *Fan) Name() string {
+	return f.Chip + "/" + f.Label
+}
+
+// readMilli reads a file holding thousandths of a unit.
+// Missing files are reported as zero.
+func readMilli(filePath string) float64 {
+	val, err := sysfs.ReadFloat(filePath)
+	if err != nil {
+		return 0
+	}
+	return val / 1000
+}
+
+// input is a file like temp1_input with the chip it belongs to.
+// prefix is the path without the _input suffix.
+type input struct {
+	prefix string
+	chip   string
+}
+
+// inputs globs for files like temp1_input in pattern.
+// Glob returns them in lexical order so the result is stable.
+func inputs(pattern string) ([]input, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []input
+	for _, p := range paths {
+		chip, err := sysfs.ReadString(filepath.Join(filepath.Dir(p), "name"))
+		if err != nil {
+			chip = filepath.Base(filepath.Dir(p))
+		}
+
+		found = append(found, input{
+			prefix: strings.TrimSuffix(p, "_input"),
+			chip:   chip,
+		})
+	}
+
+	return found, nil
+}
+
+// label reads the label of a prefix such as temp1. If the driver
+// doesn't provide one we fall back on the prefix itself.
+func label(prefix string) string {
+	l, err := sysfs.ReadString(prefix + "_label")
+	if err != nil || l == "" {
+		return filepath.Base(prefix)
+	}
+	return l
+}
+
+// GetSensors returns every temperature sensor in hwmon and every
+// thermal zone. Sensors which fail to read are skipped, but
+// ErrNoSensors is returned when nothing could be read at all.
+func GetSensors() ([]*Sensor, error) {
+	var sensors []*Sensor
+
+	temps, err := inputs(hwmonPath + "/hwmon*/temp*_input")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, t := range temps {
+		temp, err := sysfs.ReadFloat(t.prefix + "_input")
+		if err != nil {
+			continue
+		}
+
+		sensors = append(sensors, &Sensor{
+			Chip:  t.chip,
+			Label: label(t.prefix),
+			Temp:  temp / 1000,
+			Crit:  readMilli(t.prefix + "_crit"),
+			Max:   readMilli(t.prefix + "_max"),
+		})
+	}
+
+	zones, err := getThermalZones()
+	if err != nil {
+		return nil, err
+	}
+	sensors = append(sensors, zones...)
+
+	if len(sensors) == 0 {
+		return nil, ErrNoSensors
+	}
+
+	return sensors, nil
+}
+
+// getThermalZones reads /sys/class/thermal/thermal_zoneN.
+// The critical and hot trip points become Crit and Max.
+func getThermalZones() ([]*Sensor, error) {
+	zones, err := filepath.Glob(thermalPath + "/thermal_zone*")
+	if err != nil {
+		return nil, err
+	}
+
+	var sensors []*Sensor
+	for _, zone := range zones {
+		temp, err := sysfs.ReadFloat(zone + "/temp")
+		if err != nil {
+			continue
+		}
+
+		s := &Sensor{
+			Chip:  filepath.Base(zone),
+			Label: filepath.Base(zone),
+			Temp:  temp / 1000,
+		}
+		if t, err := sysfs.ReadString(zone + "/type"); err == nil {
+			s.Label = t
+		}
+
+		trips, _ := filepath.Glob(zone + "/trip_point_*_type")
+		for _, trip := range trips {
+			tripType, err := sysfs.ReadString(trip)
+			if err != nil {
+				continue
+			}
+
+			tripTemp := readMilli(strings.TrimSuffix(trip, "_type") + "_temp")
+			switch tripType {
+			case "critical":
+				s.Crit = tripTemp
+			case "hot":
+				s.Max = tripTemp
+			}
+		}
+
+		sensors = append(sensors, s)
+	}
+
+	return sensors, nil
+}
+
+// GetFans returns every fan in hwmon. Fans which fail to read are skipped.
+func GetFans() ([]*Fan, error) {
+	found, err := inputs(hwmonPath + "/hwmon*/fan*_input")
+	if err != nil {
+		return nil, err
+	}
+
+	var fans []*Fan
+	for _, f := range found {
+		rpm, err := sysfs.ReadFloat(f.prefix + "_input")
+		if err != nil {
+			continue
+		}
+
+		fans = append(fans, &Fan{
+			Chip:  f.chip,
+			Label: label(f.prefix),
+			Rpm:   rpm,
+		})
+	}
+
+	return fans, nil
+}
+
+// Hottest returns the sensor with the highest temperature.
+// We return nil for an empty slice.
+func Hottest(sensors []*Sensor) *Sensor {
+	var hottest *Sensor
+	for _, s := range sensors {
+		if hottest == nil || s.Temp > hottest.Temp {
+			hottest = s
+		}
+	}
+	return hottest
+}
+
+// Find returns the sensor whose Name or Label matches name, or nil.
+func Find(sensors []*Sensor, name string) *Sensor {
+	for _, s := range sensors {
+		if s.Name() == name || s.Label == name {
+			return s
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+/*
+linkstate implements functions for finding whether a network interface
+is up: its operational state, whether it has a carrier and whether it
+has an address. Changes are sent as events over rtnetlink, with
+/sys/class/net/<device> read for the current state.
+
+	watcher, err := linkstate.Watch()
+	// .. handle error, fall back to polling linkstate.Get
+	defer watcher.Close()
+	for event := range watcher.Events {
+		state, err := linkstate.Get(event.Device)
+		// .. handle error
+		fmt.Println(event.Device, state.Connected())
+	}
+
+When the socket's buffer overflows the lost events are replaced by one
+with Resync set. Events is closed if reading from the socket fails.
+*/
+package linkstate
+
+import (
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+const netPath = "/sys/class/net/"
+
+// OperState is the RFC 2863 operational state of an interface,
+// IF_OPER_* in linux/if.h.
+type OperState uint8
+
+const (
+	Unknown OperState = iota
+	NotPresent
+	Down
+	LowerLayerDown
+	Testing
+	Dormant
+	Up
+)
+
+var operStateNames = []string{
+	"unknown", "notpresent", "down", "lowerlayerdown",
+	"testing", "dormant", "up",
+}
+
+func (o OperState) String() string {
+	if int(o) < len(operStateNames) {
+		return operStateNames[o]
+	}
+	return operStateNames[Unknown]
+}
+
+// ParseOperState parses the contents of /sys/class/net/<device>/operstate
+func ParseOperState(s string) OperState {
+	s = strings.TrimSpace(s)
+	for i, name := range operStateNames {
+		if name == s {
+			return OperState(i)
+		}
+	}
+	return Unknown
+}
+
+// State is the state of a single interface.
+type State struct {
+	OperState OperState
+	Carrier   bool
+	// Addresses counts the addresses which aren't link local
+	Addresses int
+}
+
+// LinkUp returns true when the interface can pass traffic. Drivers
+// which don't report an operational state, such as tun devices,
+// stay unknown so we go by their carrier.
+func (s State) LinkUp() bool {
+	return s.OperState == Up || (s.OperState == Unknown && s.Carrier)
+}
+
+// Connected returns true when the link is up with an address.
+func (s State) Connected() bool {
+	return s.LinkUp() && s.Addresses > 0
+}
+
+// Get reads the state of device from /sys/class/net. A missing
+// device has the NotPresent state.
+func Get(device string) (State, error) {
+	var state State
+
+	b, err := ioutil.ReadFile(netPath + device + "/operstate")
+	if err != nil {
+		state.OperState = NotPresent
+		return state, nil
+	}
+	state.OperState = ParseOperState(string(b))
+
+	// Reading carrier fails with EINVAL while the device is down
+	b, err = ioutil.ReadFile(netPath + device + "/carrier")
+	state.Carrier = err == nil && strings.TrimSpace(string(b)) == "1"
+
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return state, err
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return state, err
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		state.Addresses++
+	}
+
+	return state, nil
+}
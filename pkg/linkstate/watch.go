@@ -0,0 +1,170 @@
+package linkstate
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+const (
+	// Multicast groups from linux/rtnetlink.h
+	rtmgrpLink       = 0x1
+	rtmgrpIPv4Ifaddr = 0x10
+	rtmgrpIPv6Ifaddr = 0x100
+	groups           = rtmgrpLink | rtmgrpIPv4Ifaddr | rtmgrpIPv6Ifaddr
+
+	// IFLA_CARRIER isn't in syscall
+	iflaCarrier = 33
+)
+
+// Event is a change to a single interface. Link events carry the new
+// operational state and carrier, address events only say that an
+// address was added or removed. A Resync event has no device, it is
+// sent when the kernel dropped events and every interface should be
+// re-read.
+type Event struct {
+	Resync bool
+
+	Device string
+	Index  int
+
+	// Link is true for RTM_NEWLINK and RTM_DELLINK
+	Link      bool
+	Removed   bool
+	OperState OperState
+	Carrier   bool
+}
+
+// Watcher sends link and address events on Events.
+type Watcher struct {
+	Events <-chan *Event
+
+	sub  *netlink.Subscription
+	done chan struct{}
+}
+
+// Watch opens an rtnetlink socket subscribed to link and address
+// changes and starts a goroutine which sends them on the returned
+// Watcher. The caller must call Close when done.
+func Watch() (*Watcher, error) {
+	sub, err := netlink.Subscribe(syscall.NETLINK_ROUTE, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *Event, 16)
+	w := &Watcher{
+		Events: events,
+		sub:    sub,
+		done:   make(chan struct{}),
+	}
+
+	go w.read(events)
+	return w, nil
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() {
+	close(w.done)
+	w.sub.Close()
+}
+
+func (w *Watcher) read(events chan<- *Event) {
+	defer close(events)
+
+	for {
+		buf, err := w.sub.Receive()
+		if err == netlink.ErrOverflow {
+			if !w.send(events, &Event{Resync: true}) {
+				return
+			}
+			continue
+		} else if err != nil {
+			return
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf)
+		if err != nil {
+			continue
+		}
+
+		for i := range msgs {
+			event, err := parseMessage(&msgs[i])
+			if err != nil || event == nil {
+				continue
+			}
+
+			if !w.send(events, event) {
+				return
+			}
+		}
+	}
+}
+
+// send returns false once the watcher is closed.
+func (w *Watcher) send(events chan<- *Event, event *Event) bool {
+	select {
+	case events <- event:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
+// parseMessage turns a link or address message into an Event.
+// Any other message returns nil.
+func parseMessage(m *syscall.NetlinkMessage) (*Event, error) {
+	event := &Event{}
+
+	switch m.Header.Type {
+	case syscall.RTM_NEWLINK, syscall.RTM_DELLINK:
+		if len(m.Data) < syscall.SizeofIfInfomsg {
+			return nil, syscall.EINVAL
+		}
+		event.Link = true
+		event.Removed = m.Header.Type == syscall.RTM_DELLINK
+		event.Index = int(int32(netlink.NativeEndian.Uint32(m.Data[4:8])))
+
+	case syscall.RTM_NEWADDR, syscall.RTM_DELADDR:
+		if len(m.Data) < syscall.SizeofIfAddrmsg {
+			return nil, syscall.EINVAL
+		}
+		event.Index = int(netlink.NativeEndian.Uint32(m.Data[4:8]))
+
+	default:
+		return nil, nil
+	}
+
+	attrs, err := syscall.ParseNetlinkRouteAttr(m)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range attrs {
+		if !event.Link {
+			break
+		}
+
+		attr := netlink.Attr{Type: a.Attr.Type, Data: a.Value}
+		switch a.Attr.Type {
+		case syscall.IFLA_IFNAME:
+			event.Device = attr.String()
+		case syscall.IFLA_OPERSTATE:
+			event.OperState = OperState(attr.Uint8())
+		case iflaCarrier:
+			event.Carrier = attr.Uint8() == 1
+		}
+	}
+
+	// Address messages only carry the name for IPv4, so look it up
+	if event.Device == "" {
+		iface, err := net.InterfaceByIndex(event.Index)
+		if err != nil {
+			return nil, err
+		}
+		event.Device = iface.Name
+	}
+
+	return event, nil
+}
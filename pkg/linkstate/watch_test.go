@@ -0,0 +1,86 @@
+package linkstate
+
+import (
+	"io/ioutil"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+func openFds(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("can't count open files :: %v", err)
+	}
+	return len(fds)
+}
+
+// toggleAddress adds and removes 127.0.0.9 on lo n times, the kernel
+// sends an address event for each. Without CAP_NET_ADMIN we skip.
+func toggleAddress(t *testing.T, n int) {
+	conn, err := netlink.Dial(syscall.NETLINK_ROUTE)
+	if err != nil {
+		t.Skipf("can't dial rtnetlink :: %v", err)
+	}
+	defer conn.Close()
+
+	lo, err := net.InterfaceByName("lo")
+	if err != nil {
+		t.Skipf("no lo :: %v", err)
+	}
+
+	ip := []byte{127, 0, 0, 9}
+	msg := make([]byte, syscall.SizeofIfAddrmsg)
+	msg[0] = syscall.AF_INET
+	msg[1] = 8
+	msg[3] = syscall.RT_SCOPE_HOST
+	netlink.NativeEndian.PutUint32(msg[4:8], uint32(lo.Index))
+	msg = append(msg, netlink.EncodeAttrs([]netlink.Attr{
+		{Type: syscall.IFA_LOCAL, Data: ip},
+		{Type: syscall.IFA_ADDRESS, Data: ip},
+	})...)
+
+	for i := 0; i < n; i++ {
+		_, err := conn.Execute(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, msg)
+		if err == syscall.EPERM {
+			t.Skip("not allowed to change addresses")
+		} else if err != nil {
+			t.Fatalf("couldn't add address :: %v", err)
+		}
+
+		if _, err := conn.Execute(syscall.RTM_DELADDR, 0, msg); err != nil {
+			t.Fatalf("couldn't remove address :: %v", err)
+		}
+	}
+}
+
+func TestWatcherCloseWhileFull(t *testing.T) {
+	before := openFds(t)
+
+	w, err := Watch()
+	if err != nil {
+		t.Skipf("can't watch rtnetlink :: %v", err)
+	}
+
+	// Nothing reads Events, so the reader ends up blocked sending
+	toggleAddress(t, cap(w.Events))
+	deadline := time.Now().Add(3 * time.Second)
+	for len(w.Events) < cap(w.Events) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(w.Events) < cap(w.Events) {
+		w.Close()
+		t.Fatalf("only %d of %d events arrived", len(w.Events), cap(w.Events))
+	}
+
+	w.Close()
+	for range w.Events {
+	}
+
+	if after := openFds(t); after != before {
+		t.Errorf("%d files open after Close, want %d", after, before)
+	}
+}
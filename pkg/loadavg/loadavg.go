@@ -0,0 +1,102 @@
+/*
+loadavg implements a function for reading /proc/loadavg.
+This holds the 1, 5 and 15 minute load averages along with
+the number of running and total tasks.
+
+	load, err := loadavg.GetLoad()
+	// .. handle error
+	fmt.Println(load.One, load.Running, load.Total)
+*/
+package loadavg
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const filePath = "/proc/loadavg"
+
+// Load is a single read of /proc/loadavg.
+type Load struct {
+	// One, Five and Fifteen are the load averages
+	// over the last 1, 5 and 15 minutes.
+	One     float64
+	Five    float64
+	Fifteen float64
+
+	// Running is the number of currently runnable tasks.
+	// Total is the number of tasks which exist on the system.
+	Running int
+	Total   int
+}
+
+// PerCpu returns a copy of the load averages divided by numCpus.
+// A value of 1.0 means every cpu has exactly one task ready to run.
+func (l *Load) PerCpu(numCpus int) *Load {
+	load := Load(*l)
+	if numCpus < 1 {
+		return &load
+	}
+
+	load.One /= float64(numCpus)
+	load.Five /= float64(numCpus)
+	load.Fifteen /= float64(numCpus)
+
+	return &load
+}
+
+// GetLoad will read /proc/loadavg.
+// All file read errors are returned.
+func GetLoad() (*Load, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseLoad(file)
+}
+
+// parseLoad parses the contents of /proc/loadavg from an io.Reader.
+// We expect a single line like "0.37 0.27 0.11 2/72 5573".
+func parseLoad(reader io.Reader) (*Load, error) {
+	raw, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := strings.Fields(string(raw))
+	if len(fields) < 4 {
+		return nil, errors.New("not enough fields in loadavg")
+	}
+
+	load := &Load{}
+	averages := []*float64{&load.One, &load.Five, &load.Fifteen}
+	for i, avg := range averages {
+		*avg, err = strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The fourth field is running/total
+	tasks := strings.Split(fields[3], "/")
+	if len(tasks) != 2 {
+		return nil, errors.New("couldn't parse tasks in loadavg")
+	}
+
+	load.Running, err = strconv.Atoi(tasks[0])
+	if err != nil {
+		return nil, err
+	}
+
+	load.Total, err = strconv.Atoi(tasks[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return load, nil
+}
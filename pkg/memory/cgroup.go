@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/jameswelchman/clark/pkg/sysfs"
+)
+
+const (
+	cgroupFile = "/proc/self/cgroup"
+	cgroupRoot = "/sys/fs/cgroup"
+)
+
+// ErrNoCgroup is returned when we are not in a cgroup v2 hierarchy
+// with the memory controller enabled.
+var ErrNoCgroup = errors.New("no cgroup v2 memory controller")
+
+// Cgroup is a single read of the memory files of a cgroup v2 scope.
+// All fields are uint64 and the units are bytes.
+type Cgroup struct {
+	// Path is relative to /sys/fs/cgroup. It is our own cgroup
+	// unless an ancestor has a lower memory.max.
+	Path string
+
+	// Current is memory.current and Max is memory.max of Path,
+	// zero means no limit.
+	Current uint64
+	Max     uint64
+
+	// Anon, File and Shmem are taken from memory.stat.
+	// File includes Shmem, as it does in the kernel.
+	Anon  uint64
+	File  uint64
+	Shmem uint64
+}
+
+// GetCgroup reads the memory files of the cgroup clark is running in.
+// This is usually the systemd scope or slice of the session.
+func GetCgroup() (*Cgroup, error) {
+	file, err := os.Open(cgroupFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	cgroupPath, err := parseCgroupPath(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Current and memory.stat are read from the cgroup which sets
+	// the limit, so that Current is measured against Max
+	limitPath, limit, err := findLimit(cgroupRoot, cgroupPath)
+	if os.IsNotExist(err) {
+		return nil, ErrNoCgroup
+	} else if err != nil {
+		return nil, err
+	}
+
+	dir := cgroupRoot + limitPath
+	current, err := sysfs.ReadUint(dir + "/memory.current")
+	if os.IsNotExist(err) {
+		return nil, ErrNoCgroup
+	} else if err != nil {
+		return nil, err
+	}
+
+	cgroup := &Cgroup{
+		Path:    limitPath,
+		Current: current,
+		Max:     limit,
+	}
+
+	stat, err := os.Open(dir + "/memory.stat")
+	if err != nil {
+		return nil, err
+	}
+	defer stat.Close()
+
+	err = parseCgroupStat(stat, cgroup)
+	if err != nil {
+		return nil, err
+	}
+
+	return cgroup, nil
+}
+
+// findLimit walks from cgroupPath up to the root looking for the
+// lowest memory.max. It returns the deepest cgroup with that limit,
+// which is cgroupPath when there is no limit at all.
+func findLimit(root string, cgroupPath string) (string, uint64, error) {
+	limitPath := cgroupPath
+	var limit uint64
+
+	for p := cgroupPath; p != "/"; p = path.Dir(p) {
+		max, err := readMax(root + p)
+		if err != nil {
+			return "", 0, err
+		}
+
+		if max != 0 && (limit == 0 || max < limit) {
+			limitPath = p
+			limit = max
+		}
+	}
+
+	return limitPath, limit, nil
+}
+
+// readMax reads memory.max in dir, "max" means no limit
+// and is returned as zero.
+func readMax(dir string) (uint64, error) {
+	raw, err := sysfs.ReadString(dir + "/memory.max")
+	if err != nil {
+		return 0, err
+	}
+
+	if raw == "max" {
+		return 0, nil
+	}
+	return strconv.ParseUint(raw, 10, 64)
+}
+
+// parseCgroupPath finds the cgroup v2 entry "0::/some/path"
+// in the contents of /proc/self/cgroup.
+func parseCgroupPath(reader io.Reader) (string, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "0::") {
+			return strings.TrimPrefix(line, "0::"), nil
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+
+	return "", ErrNoCgroup
+}
+
+// parseCgroupStat reads the keys we want from memory.stat.
+// Each line is a key and a value in bytes.
+func parseCgroupStat(reader io.Reader, cgroup *Cgroup) error {
+	fields := map[string]*uint64{
+		"anon":  &cgroup.Anon,
+		"file":  &cgroup.File,
+		"shmem": &cgroup.Shmem,
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		kv := strings.Fields(scanner.Text())
+		if len(kv) != 2 {
+			continue
+		}
+
+		field, ok := fields[kv[0]]
+		if !ok {
+			continue
+		}
+
+		value, err := strconv.ParseUint(kv[1], 10, 64)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+
+	return scanner.Err()
+}
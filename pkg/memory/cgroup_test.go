@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeCgroups creates a cgroup tree with each path's memory.max.
+func writeCgroups(t *testing.T, limits map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for p, max := range limits {
+		dir := filepath.Join(root, p)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		err := ioutil.WriteFile(filepath.Join(dir, "memory.max"), []byte(max+"\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestFindLimit(t *testing.T) {
+	const scope = "/user.slice/user-1000.slice/session-2.scope"
+
+	tests := []struct {
+		name     string
+		limits   map[string]string
+		wantPath string
+		wantMax  uint64
+	}{
+		{
+			name: "no limit",
+			limits: map[string]string{
+				"/user.slice":                 "max",
+				"/user.slice/user-1000.slice": "max",
+				scope:                         "max",
+			},
+			wantPath: scope,
+		},
+		{
+			name: "limit on the slice",
+			limits: map[string]string{
+				"/user.slice":                 "8589934592",
+				"/user.slice/user-1000.slice": "4294967296",
+				scope:                         "max",
+			},
+			wantPath: "/user.slice/user-1000.slice",
+			wantMax:  4294967296,
+		},
+		{
+			name: "same limit twice is the deepest",
+			limits: map[string]string{
+				"/user.slice":                 "4294967296",
+				"/user.slice/user-1000.slice": "4294967296",
+				scope:                         "max",
+			},
+			wantPath: "/user.slice/user-1000.slice",
+			wantMax:  4294967296,
+		},
+	}
+
+	for _, test := range tests {
+		root := writeCgroups(t, test.limits)
+		p, max, err := findLimit(root, scope)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if p != test.wantPath || max != test.wantMax {
+			t.Errorf("%s: got %s %d, want %s %d", test.name, p, max, test.wantPath, test.wantMax)
+		}
+	}
+}
+
+func TestFindLimitErrors(t *testing.T) {
+	// Only "max" means no limit, anything else we can't read is returned
+	root := writeCgroups(t, map[string]string{
+		"/user.slice":           "lots",
+		"/user.slice/app.scope": "max",
+	})
+	if _, _, err := findLimit(root, "/user.slice/app.scope"); err == nil {
+		t.Error("no error for a memory.max of lots")
+	}
+
+	root = t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "user.slice"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "user.slice", "memory.max"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := findLimit(root, "/user.slice"); err == nil {
+		t.Error("no error for an unreadable memory.max")
+	}
+
+	if _, _, err := findLimit(t.TempDir(), "/app.scope"); !os.IsNotExist(err) {
+		t.Errorf("got %v for a missing memory controller", err)
+	}
+}
@@ -1,16 +1,30 @@
 /*
-memory implemnts a function for reading /proc/meminfo.
+memory implements functions for reading /proc/meminfo.
 This contains data of how much virtual memory is used/free etc.
 
-  memAvailable, memTotal, err := memory.GetMemory()
+	info, err := memory.GetMemInfo()
+	// .. handle error
+	fmt.Println(info.MemAvailable, info.SwapFree)
 
-memAvailable and memTotal are float64 and the units are GigaBytes.
+Every field of MemInfo is a uint64 and the units are bytes, except for
+the HugePages_ counts which have no unit in /proc/meminfo.
+
+	memAvailable, memTotal, err := memory.GetMemory()
+
+GetMemory is kept for convenience. memAvailable and memTotal are
+float64 and the units are GigaBytes.
+
+	cgroup, err := memory.GetCgroup()
+
+GetCgroup reads memory.current, memory.max and memory.stat of the
+cgroup v2 scope we are running in.
 */
 package memory
 
 import (
 	"bufio"
-	"errors"
+	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -18,86 +32,228 @@ import (
 
 const filePath = "/proc/meminfo"
 
+// MemInfo holds every key of /proc/meminfo. Keys which the running
+// kernel doesn't provide are left as zero. The field names follow the
+// keys, with brackets and underscores removed.
+type MemInfo struct {
+	MemTotal          uint64
+	MemFree           uint64
+	MemAvailable      uint64
+	Buffers           uint64
+	Cached            uint64
+	SwapCached        uint64
+	Active            uint64
+	Inactive          uint64
+	ActiveAnon        uint64
+	InactiveAnon      uint64
+	ActiveFile        uint64
+	InactiveFile      uint64
+	Unevictable       uint64
+	Mlocked           uint64
+	HighTotal         uint64
+	HighFree          uint64
+	LowTotal          uint64
+	LowFree           uint64
+	SwapTotal         uint64
+	SwapFree          uint64
+	Zswap             uint64
+	Zswapped          uint64
+	Dirty             uint64
+	Writeback         uint64
+	AnonPages         uint64
+	Mapped            uint64
+	Shmem             uint64
+	KReclaimable      uint64
+	Slab              uint64
+	SReclaimable      uint64
+	SUnreclaim        uint64
+	KernelStack       uint64
+	ShadowCallStack   uint64
+	PageTables        uint64
+	SecPageTables     uint64
+	NFSUnstable       uint64
+	Bounce            uint64
+	WritebackTmp      uint64
+	CommitLimit       uint64
+	CommittedAS       uint64
+	VmallocTotal      uint64
+	VmallocUsed       uint64
+	VmallocChunk      uint64
+	Percpu            uint64
+	HardwareCorrupted uint64
+	AnonHugePages     uint64
+	ShmemHugePages    uint64
+	ShmemPmdMapped    uint64
+	FileHugePages     uint64
+	FilePmdMapped     uint64
+	CmaTotal          uint64
+	CmaFree           uint64
+	Unaccepted        uint64
+	Balloon           uint64
+	HugePagesTotal    uint64
+	HugePagesFree     uint64
+	HugePagesRsvd     uint64
+	HugePagesSurp     uint64
+	Hugepagesize      uint64
+	Hugetlb           uint64
+	DirectMap4k       uint64
+	DirectMap2M       uint64
+	DirectMap4M       uint64
+	DirectMap1G       uint64
+}
+
+// fields maps the keys of /proc/meminfo to the fields of m.
+func (m *MemInfo) fields() map[string]*uint64 {
+	return map[string]*uint64{
+		"MemTotal":          &m.MemTotal,
+		"MemFree":           &m.MemFree,
+		"MemAvailable":      &m.MemAvailable,
+		"Buffers":           &m.Buffers,
+		"Cached":            &m.Cached,
+		"SwapCached":        &m.SwapCached,
+		"Active":            &m.Active,
+		"Inactive":          &m.Inactive,
+		"Active(anon)":      &m.ActiveAnon,
+		"Inactive(anon)":    &m.InactiveAnon,
+		"Active(file)":      &m.ActiveFile,
+		"Inactive(file)":    &m.InactiveFile,
+		"Unevictable":       &m.Unevictable,
+		"Mlocked":           &m.Mlocked,
+		"HighTotal":         &m.HighTotal,
+		"HighFree":          &m.HighFree,
+		"LowTotal":          &m.LowTotal,
+		"LowFree":           &m.LowFree,
+		"SwapTotal":         &m.SwapTotal,
+		"SwapFree":          &m.SwapFree,
+		"Zswap":             &m.Zswap,
+		"Zswapped":          &m.Zswapped,
+		"Dirty":             &m.Dirty,
+		"Writeback":         &m.Writeback,
+		"AnonPages":         &m.AnonPages,
+		"Mapped":            &m.Mapped,
+		"Shmem":             &m.Shmem,
+		"KReclaimable":      &m.KReclaimable,
+		"Slab":              &m.Slab,
+		"SReclaimable":      &m.SReclaimable,
+		"SUnreclaim":        &m.SUnreclaim,
+		"KernelStack":       &m.KernelStack,
+		"ShadowCallStack":   &m.ShadowCallStack,
+		"PageTables":        &m.PageTables,
+		"SecPageTables":     &m.SecPageTables,
+		"NFS_Unstable":      &m.NFSUnstable,
+		"Bounce":            &m.Bounce,
+		"WritebackTmp":      &m.WritebackTmp,
+		"CommitLimit":       &m.CommitLimit,
+		"Committed_AS":      &m.CommittedAS,
+		"VmallocTotal":      &m.VmallocTotal,
+		"VmallocUsed":       &m.VmallocUsed,
+		"VmallocChunk":      &m.VmallocChunk,
+		"Percpu":            &m.Percpu,
+		"HardwareCorrupted": &m.HardwareCorrupted,
+		"AnonHugePages":     &m.AnonHugePages,
+		"ShmemHugePages":    &m.ShmemHugePages,
+		"ShmemPmdMapped":    &m.ShmemPmdMapped,
+		"FileHugePages":     &m.FileHugePages,
+		"FilePmdMapped":     &m.FilePmdMapped,
+		"CmaTotal":          &m.CmaTotal,
+		"CmaFree":           &m.CmaFree,
+		"Unaccepted":        &m.Unaccepted,
+		"Balloon":           &m.Balloon,
+		"HugePages_Total":   &m.HugePagesTotal,
+		"HugePages_Free":    &m.HugePagesFree,
+		"HugePages_Rsvd":    &m.HugePagesRsvd,
+		"HugePages_Surp":    &m.HugePagesSurp,
+		"Hugepagesize":      &m.Hugepagesize,
+		"Hugetlb":           &m.Hugetlb,
+		"DirectMap4k":       &m.DirectMap4k,
+		"DirectMap2M":       &m.DirectMap2M,
+		"DirectMap4M":       &m.DirectMap4M,
+		"DirectMap1G":       &m.DirectMap1G,
+	}
+}
+
+// GetMemInfo will read /proc/meminfo.
+// All file read errors are returned.
+func GetMemInfo() (*MemInfo, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseMemInfo(file)
+}
+
 // GetMemory will read /proc/meminfo and return the available
-// and used virtual memory.
+// and total virtual memory in GigaBytes.
 func GetMemory() (memAvailable, memTotal float64, err error) {
-	file, err := os.Open(filePath)
+	info, err := GetMemInfo()
 	if err != nil {
 		return 0, 0, err
 	}
-	defer file.Close()
 
-	// First loop over the file line by line
-	// The lines we want start "MemToal" and "MemAvailable"
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
+	// Change the units to GigaBytes
+	memAvailable = float64(info.MemAvailable) / 1073741824
+	memTotal = float64(info.MemTotal) / 1073741824
 
-	var memTotalStr string
-	var memAvailStr string
-	for scanner.Scan() {
-		line := scanner.Text()
+	return memAvailable, memTotal, nil
+}
 
-		if strings.HasPrefix(line, "MemTotal") {
-			memTotalStr = line
-		}
+// parseMemInfo reads the contents of /proc/meminfo from an io.Reader
+// in a single pass. Keys we don't know about are skipped.
+func parseMemInfo(reader io.Reader) (*MemInfo, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
 
-		if strings.HasPrefix(line, "MemAvailable") {
-			memAvailStr = line
+	info := &MemInfo{}
+	fields := info.fields()
+	for scanner.Scan() {
+		key, value, err := parseLine(scanner.Text())
+		if err != nil {
+			return nil, err
 		}
 
-		if len(memAvailStr) > 0 && len(memTotalStr) > 0 {
-			goto PARSE_MEMORY_LINES
+		if field, ok := fields[key]; ok {
+			*field = value
 		}
 	}
 
-	// We didn't manage to find the line
-	return 0, 0, errors.New("missing lines in /proc/meminfo")
-
-PARSE_MEMORY_LINES:
-	// We have a line like "MemTotal:  1619651 kB"
-	// just pull the numbers out.
-	memTotalStr, err = parseLine(memTotalStr)
-	if err != nil {
-		return 0, 0, err
-	}
-	memAvailStr, err = parseLine(memAvailStr)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	// Parse these numbers into floats
-	memTotal, err = strconv.ParseFloat(memTotalStr, 64)
-	if err != nil {
-		return 0, 0, err
-	}
-
-	memAvailable, err = strconv.ParseFloat(memAvailStr, 64)
-	if err != nil {
-		return 0, 0, err
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	// Change the units to GigaBytes
-	memAvailable /= 1048576
-	memTotal /= 1048576
-
-	return
+	return info, nil
 }
 
-func parseLine(line string) (string, error) {
-	// We have a line like "MemTotal:  1619651 kB"
+// parseLine parses a line like "MemTotal:  1619651 kB" and returns
+// the key and the amount in bytes. Lines without a unit such as
+// "HugePages_Total:  0" are returned as they are.
+func parseLine(line string) (string, uint64, error) {
 	// First split on the colon
-	fields := strings.Split(line, ":")
+	fields := strings.SplitN(line, ":", 2)
 	if len(fields) != 2 {
-		return "", errors.New("couldn't parse memory line")
+		return "", 0, fmt.Errorf("couldn't parse memory line %q", line)
 	}
+	key := strings.TrimSpace(fields[0])
 
-	amount := strings.TrimLeft(fields[1], "\t ")
+	// Next split the amount from the units
+	amount := strings.Fields(fields[1])
+	if len(amount) == 0 {
+		return "", 0, fmt.Errorf("no amount for %s", key)
+	}
 
-	// Next split on the space between the amout and the units
-	fields = strings.Split(amount, " ")
-	if fields[1] != "kB" {
-		return "", errors.New("memory not in kB")
+	value, err := strconv.ParseUint(amount[0], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	if len(amount) > 1 {
+		switch amount[1] {
+		case "kB":
+			value *= 1024
+		default:
+			return "", 0, fmt.Errorf("unknown unit %s for %s", amount[1], key)
+		}
 	}
 
-	return fields[0], nil
+	return key, value, nil
 }
@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"strings"
+	"testing"
+)
+
+// memInfo is /proc/meminfo from a 16G laptop, with a key from a
+// newer kernel added at the end
+const memInfo = `MemTotal:       16106252 kB
+MemFree:          893844 kB
+MemAvailable:    9270536 kB
+Buffers:          412836 kB
+Cached:          8398920 kB
+SwapCached:         3072 kB
+Active:          4763544 kB
+Inactive:        9145300 kB
+Active(anon):     352148 kB
+Inactive(anon):  5608652 kB
+Active(file):    4411396 kB
+Inactive(file):  3536648 kB
+Unevictable:      632572 kB
+Mlocked:              48 kB
+SwapTotal:       8388604 kB
+SwapFree:        8257532 kB
+Zswap:                 0 kB
+Zswapped:              0 kB
+Dirty:              1132 kB
+Writeback:             0 kB
+AnonPages:       5727460 kB
+Mapped:          1271664 kB
+Shmem:            863720 kB
+KReclaimable:     333660 kB
+Slab:             596268 kB
+SReclaimable:     333660 kB
+SUnreclaim:       262608 kB
+KernelStack:       23488 kB
+PageTables:        61660 kB
+NFS_Unstable:          0 kB
+Bounce:                0 kB
+WritebackTmp:          0 kB
+CommitLimit:    16441728 kB
+Committed_AS:   18793032 kB
+VmallocTotal:   34359738367 kB
+VmallocUsed:       80524 kB
+VmallocChunk:          0 kB
+Percpu:             8896 kB
+HardwareCorrupted:     0 kB
+AnonHugePages:         0 kB
+ShmemHugePages:        0 kB
+ShmemPmdMapped:        0 kB
+FileHugePages:         0 kB
+FilePmdMapped:         0 kB
+HugePages_Total:       0
+HugePages_Free:        0
+HugePages_Rsvd:        0
+HugePages_Surp:        0
+Hugepagesize:       2048 kB
+Hugetlb:               0 kB
+DirectMap4k:      445380 kB
+DirectMap2M:    10928128 kB
+DirectMap1G:     5242880 kB
+Mystery(new):        512 kB
+`
+
+func TestParseMemInfo(t *testing.T) {
+	info, err := parseMemInfo(strings.NewReader(memInfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  uint64
+		want uint64
+	}{
+		{"MemTotal", info.MemTotal, 16106252 * 1024},
+		{"MemAvailable", info.MemAvailable, 9270536 * 1024},
+		{"Active(anon)", info.ActiveAnon, 352148 * 1024},
+		{"SwapFree", info.SwapFree, 8257532 * 1024},
+		{"Committed_AS", info.CommittedAS, 18793032 * 1024},
+		{"VmallocTotal", info.VmallocTotal, 34359738367 * 1024},
+		{"HugePages_Total", info.HugePagesTotal, 0},
+		{"Hugepagesize", info.Hugepagesize, 2048 * 1024},
+		{"DirectMap1G", info.DirectMap1G, 5242880 * 1024},
+		// Not in the sample
+		{"DirectMap4M", info.DirectMap4M, 0},
+	}
+
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s got %d, want %d", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestParseLine(t *testing.T) {
+	// Counts have no unit and are kept as they are
+	key, value, err := parseLine("HugePages_Free:      12")
+	if err != nil || key != "HugePages_Free" || value != 12 {
+		t.Errorf("got %s %d %v, want HugePages_Free 12", key, value, err)
+	}
+
+	for _, line := range []string{
+		"MemTotal 16106252 kB",
+		"MemTotal:",
+		"MemTotal:       lots kB",
+		"MemTotal:       16106252 MB",
+	} {
+		if _, _, err := parseLine(line); err == nil {
+			t.Errorf("no error for %q", line)
+		}
+	}
+}
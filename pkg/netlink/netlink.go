@@ -0,0 +1,304 @@
+/*
+netlink implements just enough of the netlink protocol to send requests
+and parse the replies, including the generic netlink families used by
+nl80211 and wireguard.
+
+	conn, err := netlink.Dial(syscall.NETLINK_GENERIC)
+	// .. handle error
+	defer conn.Close()
+	family, err := conn.ResolveFamily("nl80211")
+	// .. handle error
+	replies, err := conn.Execute(family, 0, netlink.GenlMessage(cmd, 0, attrs))
+
+Every reply is the payload of one netlink message, without its header.
+Attributes are parsed with ParseAttrs. Everything is in host byte order.
+*/
+package netlink
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	headerLen = syscall.NLMSG_HDRLEN
+	attrLen   = syscall.SizeofRtAttr
+	genlLen   = 4
+
+	// How long we wait for the kernel to reply
+	readTimeout = 2 * time.Second
+
+	// Generic netlink controller, from linux/genetlink.h
+	genlIdCtrl          = 0x10
+	ctrlCmdGetFamily    = 3
+	ctrlAttrFamilyId    = 1
+	ctrlAttrFamilyName  = 2
+	nlaFNested          = 1 << 15
+	nlaFNetByteOrder    = 1 << 14
+	nlaTypeMask         = ^uint16(nlaFNested | nlaFNetByteOrder)
+	maxReplyMessageSize = 65536
+)
+
+// NativeEndian is the byte order of netlink messages.
+var NativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// Conn is a netlink socket.
+type Conn struct {
+	fd  int
+	seq uint32
+}
+
+// Dial opens a netlink socket for protocol, such as
+// syscall.NETLINK_GENERIC or syscall.NETLINK_ROUTE.
+func Dial(protocol int) (*Conn, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	tv := syscall.NsecToTimeval(readTimeout.Nanoseconds())
+	err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return &Conn{fd: fd}, nil
+}
+
+// Close closes the socket.
+func (c *Conn) Close() error {
+	return syscall.Close(c.fd)
+}
+
+// Execute sends a single request and returns the payload of every
+// reply. NLM_F_REQUEST and NLM_F_ACK are always set, add
+// syscall.NLM_F_DUMP to flags to ask for every object.
+// An error reply from the kernel is returned as a syscall.Errno.
+func (c *Conn) Execute(msgType uint16, flags uint16, payload []byte) ([][]byte, error) {
+	c.seq++
+	seq := c.seq
+
+	msg := make([]byte, headerLen, headerLen+len(payload))
+	NativeEndian.PutUint32(msg[0:4], uint32(headerLen+len(payload)))
+	NativeEndian.PutUint16(msg[4:6], msgType)
+	NativeEndian.PutUint16(msg[6:8], flags|syscall.NLM_F_REQUEST|syscall.NLM_F_ACK)
+	NativeEndian.PutUint32(msg[8:12], seq)
+	msg = append(msg, payload...)
+
+	err := syscall.Sendto(c.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK})
+	if err != nil {
+		return nil, err
+	}
+
+	var replies [][]byte
+	buf := make([]byte, maxReplyMessageSize)
+	for {
+		n, _, err := syscall.Recvfrom(c.fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return replies, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) < 4 {
+					return nil, errors.New("short netlink error")
+				}
+				// Zero is the acknowledgement of a request
+				errno := int32(NativeEndian.Uint32(m.Data[0:4]))
+				if errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				return replies, nil
+			default:
+				data := make([]byte, len(m.Data))
+				copy(data, m.Data)
+				replies = append(replies, data)
+			}
+		}
+	}
+}
+
+// Attr is a single netlink attribute.
+type Attr struct {
+	Type uint16
+	Data []byte
+}
+
+// ParseAttrs parses a buffer of attributes. The nested and byte
+// order flags are removed from Type.
+func ParseAttrs(b []byte) ([]Attr, error) {
+	var attrs []Attr
+	for len(b) >= attrLen {
+		length := int(NativeEndian.Uint16(b[0:2]))
+		if length < attrLen || length > len(b) {
+			return nil, errors.New("bad netlink attribute length")
+		}
+
+		attrs = append(attrs, Attr{
+			Type: NativeEndian.Uint16(b[2:4]) & nlaTypeMask,
+			Data: b[attrLen:length],
+		})
+
+		// The last attribute may not be padded
+		next := align(length)
+		if next > len(b) {
+			next = len(b)
+		}
+		b = b[next:]
+	}
+
+	return attrs, nil
+}
+
+// EncodeAttrs builds a buffer of attributes, padding each one.
+func EncodeAttrs(attrs []Attr) []byte {
+	var b []byte
+	for _, a := range attrs {
+		length := attrLen + len(a.Data)
+		header := make([]byte, attrLen)
+		NativeEndian.PutUint16(header[0:2], uint16(length))
+		NativeEndian.PutUint16(header[2:4], a.Type)
+
+		b = append(b, header...)
+		b = append(b, a.Data...)
+		b = append(b, make([]byte, align(length)-length)...)
+	}
+	return b
+}
+
+// align rounds up to the four byte netlink alignment.
+func align(length int) int {
+	return (length + syscall.NLA_ALIGNTO - 1) &^ (syscall.NLA_ALIGNTO - 1)
+}
+
+// Uint8 returns the attribute as a uint8, or zero if it's too short.
+func (a Attr) Uint8() uint8 {
+	if len(a.Data) < 1 {
+		return 0
+	}
+	return a.Data[0]
+}
+
+// Uint16 returns the attribute as a uint16, or zero if it's too short.
+func (a Attr) Uint16() uint16 {
+	if len(a.Data) < 2 {
+		return 0
+	}
+	return NativeEndian.Uint16(a.Data)
+}
+
+// Uint32 returns the attribute as a uint32, or zero if it's too short.
+func (a Attr) Uint32() uint32 {
+	if len(a.Data) < 4 {
+		return 0
+	}
+	return NativeEndian.Uint32(a.Data)
+}
+
+// Uint64 returns the attribute as a uint64, or zero if it's too short.
+func (a Attr) Uint64() uint64 {
+	if len(a.Data) < 8 {
+		return 0
+	}
+	return NativeEndian.Uint64(a.Data)
+}
+
+// String returns the attribute without its NUL terminator.
+func (a Attr) String() string {
+	data := a.Data
+	for len(data) > 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	return string(data)
+}
+
+// Nested parses the attribute as a buffer of attributes.
+func (a Attr) Nested() ([]Attr, error) {
+	return ParseAttrs(a.Data)
+}
+
+// Uint32Attr builds a uint32 attribute.
+func Uint32Attr(attrType uint16, value uint32) Attr {
+	data := make([]byte, 4)
+	NativeEndian.PutUint32(data, value)
+	return Attr{Type: attrType, Data: data}
+}
+
+// StringAttr builds a NUL terminated string attribute.
+func StringAttr(attrType uint16, value string) Attr {
+	return Attr{Type: attrType, Data: append([]byte(value), 0)}
+}
+
+// GenlMessage builds a generic netlink payload: the genlmsghdr
+// holding the command and version, followed by the attributes.
+func GenlMessage(cmd, version uint8, attrs []Attr) []byte {
+	return append([]byte{cmd, version, 0, 0}, EncodeAttrs(attrs)...)
+}
+
+// ParseGenl splits a generic netlink reply into its command and
+// attributes.
+func ParseGenl(reply []byte) (uint8, []Attr, error) {
+	if len(reply) < genlLen {
+		return 0, nil, errors.New("short generic netlink message")
+	}
+
+	attrs, err := ParseAttrs(reply[genlLen:])
+	return reply[0], attrs, err
+}
+
+// ResolveFamily asks the generic netlink controller for the message
+// type of a family such as "nl80211" or "wireguard". ENOENT is
+// returned when the family isn't loaded.
+func (c *Conn) ResolveFamily(name string) (uint16, error) {
+	replies, err := c.Execute(genlIdCtrl, 0, GenlMessage(ctrlCmdGetFamily, 1,
+		[]Attr{StringAttr(ctrlAttrFamilyName, name)}))
+	if err != nil {
+		return 0, err
+	}
+
+	for _, reply := range replies {
+		_, attrs, err := ParseGenl(reply)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, a := range attrs {
+			if a.Type == ctrlAttrFamilyId {
+				return a.Uint16(), nil
+			}
+		}
+	}
+
+	return 0, syscall.ENOENT
+}
@@ -0,0 +1,114 @@
+package netlink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func skipBigEndian(t *testing.T) {
+	if NativeEndian != binary.LittleEndian {
+		t.Skip("captured messages are little endian")
+	}
+}
+
+func TestParseAttrs(t *testing.T) {
+	skipBigEndian(t)
+
+	msg := []byte{
+		// u32 type 3 = 7
+		0x08, 0x00, 0x03, 0x00, 0x07, 0x00, 0x00, 0x00,
+		// "wlan0" type 4, padded by two bytes
+		0x0a, 0x00, 0x04, 0x00, 'w', 'l', 'a', 'n', '0', 0x00, 0x00, 0x00,
+		// nested type 21 with NLA_F_NESTED, holding a u8 type 7
+		0x0c, 0x00, 0x15, 0x80, 0x05, 0x00, 0x07, 0x00, 0xd8, 0x00, 0x00, 0x00,
+	}
+
+	attrs, err := ParseAttrs(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 3 {
+		t.Fatalf("got %d attributes, want 3", len(attrs))
+	}
+
+	if attrs[0].Type != 3 || attrs[0].Uint32() != 7 {
+		t.Errorf("attribute 0 is %d = %d", attrs[0].Type, attrs[0].Uint32())
+	}
+	if attrs[1].Type != 4 || attrs[1].String() != "wlan0" {
+		t.Errorf("attribute 1 is %d = %q", attrs[1].Type, attrs[1].String())
+	}
+	if attrs[2].Type != 21 {
+		t.Errorf("attribute 2 has type %#x, the nested flag should be removed", attrs[2].Type)
+	}
+
+	nested, err := attrs[2].Nested()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nested) != 1 || nested[0].Type != 7 || int8(nested[0].Uint8()) != -40 {
+		t.Errorf("nested attributes %+v", nested)
+	}
+}
+
+func TestParseAttrsUnpaddedLast(t *testing.T) {
+	// The final attribute may end without its padding
+	attrs, err := ParseAttrs([]byte{0x05, 0x00, 0x01, 0x00, 0x2a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(attrs) != 1 || attrs[0].Uint8() != 42 {
+		t.Errorf("got %+v", attrs)
+	}
+}
+
+func TestParseAttrsBad(t *testing.T) {
+	tests := map[string][]byte{
+		"length past the end": {0x0c, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"length below header": {0x02, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+		"truncated second": {
+			0x08, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00,
+			0x08, 0x00, 0x02, 0x00, 0x01, 0x00,
+		},
+	}
+
+	for name, msg := range tests {
+		if _, err := ParseAttrs(msg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestShortAttrs(t *testing.T) {
+	a := Attr{Type: 1, Data: []byte{0x01}}
+	if a.Uint16() != 0 || a.Uint32() != 0 || a.Uint64() != 0 {
+		t.Error("short attributes should read as zero")
+	}
+}
+
+func TestEncodeAttrs(t *testing.T) {
+	skipBigEndian(t)
+
+	got := EncodeAttrs([]Attr{Uint32Attr(3, 7), StringAttr(2, "wg0")})
+	want := []byte{
+		0x08, 0x00, 0x03, 0x00, 0x07, 0x00, 0x00, 0x00,
+		0x08, 0x00, 0x02, 0x00, 'w', 'g', '0', 0x00,
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got % x, want % x", got, want)
+	}
+}
+
+func TestGenlRoundTrip(t *testing.T) {
+	cmd, attrs, err := ParseGenl(GenlMessage(5, 1, []Attr{StringAttr(2, "nl80211")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != 5 || len(attrs) != 1 || attrs[0].String() != "nl80211" {
+		t.Errorf("got command %d and %+v", cmd, attrs)
+	}
+
+	if _, _, err := ParseGenl([]byte{5, 1}); err == nil {
+		t.Error("a short message should be an error")
+	}
+}
@@ -0,0 +1,140 @@
+package netlink
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// How often a blocked Receive wakes up to check for Close
+	wakeInterval = time.Second
+
+	subscribeBufferSize = 16384
+)
+
+var (
+	// ErrOverflow is returned by Receive when the socket's buffer
+	// overflowed and messages were lost. The socket still works,
+	// but the caller should re-read whatever state it follows.
+	ErrOverflow = errors.New("netlink messages lost")
+
+	// ErrClosed is returned by Receive once Close has been called.
+	ErrClosed = errors.New("netlink subscription closed")
+)
+
+// Subscription is a netlink socket bound to multicast groups, such as
+// kobject uevents or rtnetlink link changes.
+//
+//	sub, err := netlink.Subscribe(syscall.NETLINK_KOBJECT_UEVENT, 1)
+//	// .. handle error
+//	defer sub.Close()
+//	for {
+//		msg, err := sub.Receive()
+//		if err == netlink.ErrOverflow {
+//			// re-read everything
+//		}
+//	}
+type Subscription struct {
+	buf []byte
+
+	// mu is held while reading, so Close can't close the
+	// fd, or let it be reused, under a blocked Receive.
+	// closing is set first so Receive stops taking mu.
+	mu      sync.Mutex
+	fd      int
+	closed  bool
+	closing int32
+}
+
+// Subscribe opens a netlink socket for protocol and joins the
+// multicast groups, a bitmask.
+func Subscribe(protocol int, groups uint32) (*Subscription, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK,
+		syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, protocol)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Bind(fd, &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: groups,
+	})
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	tv := syscall.NsecToTimeval(wakeInterval.Nanoseconds())
+	err = syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+
+	return &Subscription{
+		fd:  fd,
+		buf: make([]byte, subscribeBufferSize),
+	}, nil
+}
+
+// Close closes the socket. It may be called from any goroutine and
+// more than once, a blocked Receive wakes up within wakeInterval and
+// returns ErrClosed, which is how long Close may wait for it.
+func (s *Subscription) Close() {
+	atomic.StoreInt32(&s.closing, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		syscall.Close(s.fd)
+		s.closed = true
+	}
+}
+
+// Receive blocks until a message from the kernel arrives and returns
+// it. Messages from other processes, such as udev, are skipped. The
+// message is only valid until the next call. Errors other than
+// ErrOverflow close the socket and are returned from every later call.
+func (s *Subscription) Receive() ([]byte, error) {
+	for {
+		n, from, err := s.recv()
+		switch err {
+		case nil:
+		case syscall.EAGAIN, syscall.EINTR:
+			continue
+		case syscall.ENOBUFS:
+			return nil, ErrOverflow
+		case ErrClosed:
+			return nil, err
+		default:
+			s.Close()
+			return nil, err
+		}
+
+		// Only trust messages from the kernel itself
+		if sa, ok := from.(*syscall.SockaddrNetlink); !ok || sa.Pid != 0 {
+			continue
+		}
+
+		return s.buf[:n], nil
+	}
+}
+
+// recv reads once, waking up after wakeInterval with EAGAIN.
+func (s *Subscription) recv() (int, syscall.Sockaddr, error) {
+	if atomic.LoadInt32(&s.closing) == 1 {
+		return 0, nil, ErrClosed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return 0, nil, ErrClosed
+	}
+	return syscall.Recvfrom(s.fd, s.buf, 0)
+}
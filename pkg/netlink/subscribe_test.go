@@ -0,0 +1,53 @@
+package netlink
+
+import (
+	"io/ioutil"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// openFds counts the open file descriptors of the test.
+func openFds(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("can't count open files :: %v", err)
+	}
+	return len(fds)
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	before := openFds(t)
+
+	sub, err := Subscribe(syscall.NETLINK_KOBJECT_UEVENT, 1)
+	if err != nil {
+		t.Skipf("can't subscribe to uevents :: %v", err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := sub.Receive()
+		for err == nil {
+			_, err = sub.Receive()
+		}
+		errCh <- err
+	}()
+
+	// Let Receive block before closing under it
+	time.Sleep(100 * time.Millisecond)
+	sub.Close()
+	sub.Close()
+
+	select {
+	case err := <-errCh:
+		if err != ErrClosed {
+			t.Errorf("Receive returned %v, want ErrClosed", err)
+		}
+	case <-time.After(3 * wakeInterval):
+		t.Fatal("Receive didn't return after Close")
+	}
+
+	if after := openFds(t); after != before {
+		t.Errorf("%d files open after Close, want %d", after, before)
+	}
+}
@@ -0,0 +1,118 @@
+package probe
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+const (
+	icmpEchoRequest   = 8
+	icmpEchoReply     = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+
+	icmpHeaderLen = 8
+)
+
+// ICMP pings Address, a host or IP address. It uses an unprivileged
+// ICMP socket so it needs neither root nor the ping binary, but the
+// user's group must be within the sysctl net.ipv4.ping_group_range.
+type ICMP struct {
+	Address string
+}
+
+// Probe implements Probe. Only the deadline of ctx is honoured
+// once the echo request has been sent, not its cancellation.
+func (i ICMP) Probe(ctx context.Context) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, i.Address)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return errors.New("no addresses for " + i.Address)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(5 * time.Second)
+	}
+
+	if ip4 := addrs[0].IP.To4(); ip4 != nil {
+		sa := &syscall.SockaddrInet4{}
+		copy(sa.Addr[:], ip4)
+		return ping(syscall.AF_INET, syscall.IPPROTO_ICMP, sa,
+			icmpEchoRequest, icmpEchoReply, deadline)
+	}
+
+	sa := &syscall.SockaddrInet6{}
+	copy(sa.Addr[:], addrs[0].IP.To16())
+	return ping(syscall.AF_INET6, syscall.IPPROTO_ICMPV6, sa,
+		icmpv6EchoRequest, icmpv6EchoReply, deadline)
+}
+
+// ping sends one echo request and waits for its reply until the
+// deadline. The kernel fills in the identifier and only gives us
+// replies to our own socket.
+func ping(family, proto int, sa syscall.Sockaddr, request, reply byte, deadline time.Time) error {
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, proto)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	const seq = 1
+	msg := []byte{request, 0, 0, 0, 0, 0, 0, seq}
+	if family == syscall.AF_INET {
+		sum := checksum(msg)
+		msg[2], msg[3] = byte(sum>>8), byte(sum)
+	}
+
+	if err := syscall.Sendto(fd, msg, 0, sa); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		timeout := time.Until(deadline)
+		if timeout <= 0 {
+			return syscall.ETIMEDOUT
+		}
+
+		tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+		err := syscall.SetsockoptTimeval(fd, syscall.SOL_SOCKET, syscall.SO_RCVTIMEO, &tv)
+		if err != nil {
+			return err
+		}
+
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		} else if err == syscall.EAGAIN {
+			return syscall.ETIMEDOUT
+		} else if err != nil {
+			return err
+		}
+
+		if n >= icmpHeaderLen && buf[0] == reply && buf[7] == seq {
+			return nil
+		}
+	}
+}
+
+// checksum is the internet checksum from RFC 1071.
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,110 @@
+/*
+probe implements checks for whether we can reach the internet. Each
+Probe tries something different so that a network which blocks one
+kind of traffic can still be checked with another.
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := probe.TCP{Address: "1.1.1.1:443"}.Probe(ctx)
+	if err == probe.ErrCaptivePortal {
+		// we need to log in
+	}
+
+A nil error means the target was reached.
+*/
+package probe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// ErrCaptivePortal is returned by HTTP when the reply wasn't the 204
+// we asked for, usually because a captive portal has intercepted it.
+var ErrCaptivePortal = errors.New("captive portal")
+
+// Probe checks whether a target can be reached. It must give up
+// when ctx is done.
+type Probe interface {
+	Probe(ctx context.Context) error
+}
+
+// TCP connects to Address, a host:port.
+type TCP struct {
+	Address string
+}
+
+// Probe implements Probe.
+func (t TCP) Probe(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.Address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// DNS looks up Host. When Server, a host:port, is set it is asked
+// instead of the system resolver.
+type DNS struct {
+	Host   string
+	Server string
+}
+
+// Probe implements Probe.
+func (d DNS) Probe(ctx context.Context) error {
+	resolver := net.DefaultResolver
+	if d.Server != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, d.Server)
+			},
+		}
+	}
+
+	addrs, err := resolver.LookupHost(ctx, d.Host)
+	if err != nil {
+		return err
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("no addresses for %s", d.Host)
+	}
+	return nil
+}
+
+// HTTP fetches URL, which must reply with 204 No Content such as
+// http://clients3.google.com/generate_204. Anything else, including
+// a redirect, is taken to be a captive portal.
+type HTTP struct {
+	URL string
+}
+
+// Probe implements Probe.
+func (h HTTP) Probe(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, h.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return ErrCaptivePortal
+	}
+	return nil
+}
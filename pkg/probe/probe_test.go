@@ -0,0 +1,207 @@
+package probe
+
+import (
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testContext(t *testing.T, timeout time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	err = TCP{Address: addr}.Probe(testContext(t, 5*time.Second))
+	if err != nil {
+		t.Errorf("open port: %v", err)
+	}
+
+	l.Close()
+	err = TCP{Address: addr}.Probe(testContext(t, 5*time.Second))
+	if err == nil {
+		t.Error("closed port: expected an error")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	noContent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer noContent.Close()
+
+	portal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	}))
+	defer portal.Close()
+
+	err := HTTP{URL: noContent.URL}.Probe(testContext(t, 5*time.Second))
+	if err != nil {
+		t.Errorf("204: %v", err)
+	}
+
+	err = HTTP{URL: portal.URL}.Probe(testContext(t, 5*time.Second))
+	if err != ErrCaptivePortal {
+		t.Errorf("302: got %v, want ErrCaptivePortal", err)
+	}
+}
+
+// serveDNS answers A queries for found.test with 127.0.0.1 and
+// everything else with NXDOMAIN, until conn is closed.
+func serveDNS(conn net.PacketConn) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n < 12 {
+			continue
+		}
+
+		// The question is the name, then two bytes each of type and class
+		end := 12
+		var labels []string
+		for end < n && buf[end] != 0 {
+			length := int(buf[end])
+			labels = append(labels, string(buf[end+1:end+1+length]))
+			end += 1 + length
+		}
+		end += 5
+		if end > n {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(buf[end-4 : end-2])
+		name := strings.Join(labels, ".")
+
+		resp := append([]byte{}, buf[:end]...)
+		// Response, recursion desired and available, one question
+		binary.BigEndian.PutUint16(resp[2:4], 0x8180)
+		binary.BigEndian.PutUint16(resp[6:8], 0)
+		binary.BigEndian.PutUint16(resp[8:10], 0)
+		binary.BigEndian.PutUint16(resp[10:12], 0)
+
+		switch {
+		case name != "found.test":
+			binary.BigEndian.PutUint16(resp[2:4], 0x8183)
+		case qtype == 1:
+			binary.BigEndian.PutUint16(resp[6:8], 1)
+			resp = append(resp,
+				0xc0, 12, // pointer to the question's name
+				0, 1, 0, 1, // A, IN
+				0, 0, 0, 60, // ttl
+				0, 4, 127, 0, 0, 1)
+		}
+
+		conn.WriteTo(resp, addr)
+	}
+}
+
+func TestDNS(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go serveDNS(conn)
+
+	server := conn.LocalAddr().String()
+
+	err = DNS{Host: "found.test", Server: server}.Probe(testContext(t, 5*time.Second))
+	if err != nil {
+		t.Errorf("found.test: %v", err)
+	}
+
+	err = DNS{Host: "missing.test", Server: server}.Probe(testContext(t, 5*time.Second))
+	if err == nil {
+		t.Error("missing.test: expected an error")
+	}
+}
+
+// pingAllowed reports whether our group may open ICMP sockets.
+func pingAllowed() bool {
+	b, err := ioutil.ReadFile("/proc/sys/net/ipv4/ping_group_range")
+	if err != nil {
+		return false
+	}
+
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return false
+	}
+	low, err1 := strconv.Atoi(fields[0])
+	high, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	gid := os.Getgid()
+	return low <= gid && gid <= high
+}
+
+func TestICMP(t *testing.T) {
+	if !pingAllowed() {
+		t.Skip("net.ipv4.ping_group_range doesn't allow ICMP sockets")
+	}
+
+	err := ICMP{Address: "127.0.0.1"}.Probe(testContext(t, 5*time.Second))
+	if err != nil {
+		t.Errorf("127.0.0.1: %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	// Accepts connections but never replies
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// Reads queries but never answers
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	probes := map[string]Probe{
+		"http": HTTP{URL: "http://" + l.Addr().String() + "/generate_204"},
+		"dns":  DNS{Host: "found.test", Server: conn.LocalAddr().String()},
+	}
+
+	for name, p := range probes {
+		start := time.Now()
+		err := p.Probe(testContext(t, 200*time.Millisecond))
+		if err == nil {
+			t.Errorf("%s: expected a timeout", name)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: took %v to time out", name, elapsed)
+		}
+	}
+}
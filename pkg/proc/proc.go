@@ -0,0 +1,238 @@
+/*
+proc implements a sampler for the per process files /proc/[pid]/stat
+and /proc/[pid]/statm. Comparing two samples gives the cpu usage of
+each process, statm gives the resident set size.
+
+	client := proc.NewClient()
+	processes, err := client.Sample()
+	// .. handle error
+	top := proc.Top(processes, 5, proc.ByCpu)
+
+Cpu is a float64 percentage of a single cpu, so a process using two
+cpus fully reports 200. Rss is a uint64 and the units are bytes.
+*/
+package proc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	filePath = "/proc"
+
+	// clockTicks is USER_HZ, the unit of utime and stime in
+	// /proc/[pid]/stat. It is 100 on every Linux architecture.
+	clockTicks = 100
+)
+
+type (
+	// Process is a single sample of one process.
+	Process struct {
+		Pid  int
+		Name string
+		Cpu  float64
+		Rss  uint64
+	}
+
+	// Client holds the cpu times of the last sample so that
+	// the next one can compute cpu usage.
+	Client struct {
+		ticks    map[int]uint64
+		readTime time.Time
+		pageSize uint64
+
+		// buf is reused for every file we read
+		buf []byte
+	}
+
+	// Less orders processes for Top.
+	Less func(a, b *Process) bool
+)
+
+// ByCpu sorts the busiest process first.
+func ByCpu(a, b *Process) bool {
+	return a.Cpu > b.Cpu
+}
+
+// ByRss sorts the largest process first.
+func ByRss(a, b *Process) bool {
+	return a.Rss > b.Rss
+}
+
+// NewClient creates a new instance of the client
+func NewClient() *Client {
+	return &Client{
+		ticks:    map[int]uint64{},
+		pageSize: uint64(os.Getpagesize()),
+		buf:      make([]byte, 1024),
+	}
+}
+
+// Sample will read stat and statm for every process.
+// Cpu usage is computed since the *last* call to Sample,
+// on the first call every process reports zero.
+// Processes which exit while we read them are skipped.
+func (c *Client) Sample() ([]*Process, error) {
+	dir, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	names, err := dir.Readdirnames(-1)
+	dir.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	readTime := time.Now()
+	period := readTime.Sub(c.readTime).Seconds()
+	first := c.readTime.IsZero()
+
+	ticks := make(map[int]uint64, len(c.ticks))
+	var processes []*Process
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			// Not a process directory
+			continue
+		}
+
+		p, total, err := c.readProcess(pid)
+		if err != nil {
+			continue
+		}
+		ticks[pid] = total
+
+		// Processes started since the last sample
+		// have used all of their ticks in the period.
+		if !first && period > 0 {
+			prev := c.ticks[pid]
+			if total >= prev {
+				p.Cpu = float64(total-prev) / clockTicks / period * 100
+			}
+		}
+
+		processes = append(processes, p)
+	}
+
+	c.ticks = ticks
+	c.readTime = readTime
+
+	return processes, nil
+}
+
+// readProcess reads stat and statm for a single pid.
+// We return the process and its total cpu ticks.
+func (c *Client) readProcess(pid int) (*Process, uint64, error) {
+	dir := filePath + "/" + strconv.Itoa(pid)
+
+	stat, err := c.readFile(dir + "/stat")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	name, total, err := parseStat(stat)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	statm, err := c.readFile(dir + "/statm")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	pages, err := parseStatm(statm)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &Process{
+		Pid:  pid,
+		Name: name,
+		Rss:  pages * c.pageSize,
+	}, total, nil
+}
+
+// readFile reads a small file into the shared buffer.
+// The returned slice is only valid until the next call.
+func (c *Client) readFile(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	n := 0
+	for {
+		if n == len(c.buf) {
+			c.buf = append(c.buf, make([]byte, len(c.buf))...)
+		}
+
+		m, err := file.Read(c.buf[n:])
+		n += m
+		if err == io.EOF {
+			return c.buf[:n], nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parseStat returns the command name and utime+stime from the
+// contents of /proc/[pid]/stat. The name is in brackets and may
+// itself contain spaces and brackets, so we split on the last ')'.
+func parseStat(stat []byte) (string, uint64, error) {
+	start := bytes.IndexByte(stat, '(')
+	end := bytes.LastIndexByte(stat, ')')
+	if start < 0 || end < start {
+		return "", 0, errors.New("couldn't find name in stat")
+	}
+	name := string(stat[start+1 : end])
+
+	// Fields after the name start with state, utime and stime
+	// are the 14th and 15th fields of the whole line.
+	fields := bytes.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return "", 0, errors.New("not enough fields in stat")
+	}
+
+	utime, err := strconv.ParseUint(string(fields[11]), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	stime, err := strconv.ParseUint(string(fields[12]), 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return name, utime + stime, nil
+}
+
+// parseStatm returns the resident pages from /proc/[pid]/statm.
+func parseStatm(statm []byte) (uint64, error) {
+	fields := bytes.Fields(statm)
+	if len(fields) < 2 {
+		return 0, errors.New("not enough fields in statm")
+	}
+
+	return strconv.ParseUint(string(fields[1]), 10, 64)
+}
+
+// Top sorts processes with less and returns at most n of them.
+func Top(processes []*Process, n int, less Less) []*Process {
+	sort.Slice(processes, func(i, j int) bool {
+		return less(processes[i], processes[j])
+	})
+
+	if len(processes) > n {
+		processes = processes[:n]
+	}
+
+	return processes
+}
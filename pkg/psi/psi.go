@@ -0,0 +1,141 @@
+/*
+psi implements a function for reading the pressure stall information
+in /proc/pressure. This holds the share of time in which some or all
+tasks were stalled waiting on the cpu, memory or io.
+
+	pressure, err := psi.GetPressure(psi.Memory)
+	if err == psi.ErrNotSupported {
+		// the kernel was built or booted without psi
+	}
+	fmt.Println(pressure.Some.Avg10, pressure.Full.Avg10)
+
+The averages are float64 percentages and Total is in microseconds.
+*/
+package psi
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const filePath = "/proc/pressure/"
+
+// The resources which may be passed to GetPressure
+const (
+	Cpu    = "cpu"
+	Memory = "memory"
+	Io     = "io"
+)
+
+// ErrNotSupported is returned when the kernel has no psi support.
+var ErrNotSupported = errors.New("pressure stall information not supported")
+
+type (
+	// Stat is one line of a pressure file.
+	Stat struct {
+		Avg10  float64
+		Avg60  float64
+		Avg300 float64
+		Total  uint64
+	}
+
+	// Pressure is a single read of a pressure file.
+	// Full is zero for cpu on kernels which only report some.
+	Pressure struct {
+		Some Stat
+		Full Stat
+	}
+)
+
+// GetPressure will read /proc/pressure/<resource>.
+// ErrNotSupported is returned when the file doesn't exist, or when
+// psi was disabled on the kernel command line. All other file read
+// errors are returned.
+func GetPressure(resource string) (*Pressure, error) {
+	file, err := os.Open(filePath + resource)
+	if os.IsNotExist(err) {
+		return nil, ErrNotSupported
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	pressure, err := parsePressure(file)
+	if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EOPNOTSUPP {
+		return nil, ErrNotSupported
+	}
+
+	return pressure, err
+}
+
+// parsePressure reads a pressure file from an io.Reader.
+// We expect lines like
+//
+//	some avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(reader io.Reader) (*Pressure, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	pressure := &Pressure{}
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		var stat *Stat
+		switch fields[0] {
+		case "some":
+			stat = &pressure.Some
+		case "full":
+			stat = &pressure.Full
+		default:
+			return nil, fmt.Errorf("unknown pressure line %s", fields[0])
+		}
+
+		err := parseStat(fields[1:], stat)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return pressure, nil
+}
+
+// parseStat parses the key=value fields of a single line.
+// Unknown keys are ignored.
+func parseStat(fields []string, stat *Stat) error {
+	for _, f := range fields {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("couldn't parse pressure field %s", f)
+		}
+
+		var err error
+		switch kv[0] {
+		case "avg10":
+			stat.Avg10, err = strconv.ParseFloat(kv[1], 64)
+		case "avg60":
+			stat.Avg60, err = strconv.ParseFloat(kv[1], 64)
+		case "avg300":
+			stat.Avg300, err = strconv.ParseFloat(kv[1], 64)
+		case "total":
+			stat.Total, err = strconv.ParseUint(kv[1], 10, 64)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+/*
+route implements functions for finding the default routes in
+/proc/net/route and /proc/net/ipv6_route. The interface carrying the
+default route is the one our traffic to the internet goes over.
+
+	device, err := route.GetDefaultInterface()
+	if err == route.ErrNoRoute {
+		// we are offline
+	}
+*/
+package route
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ipv4Path = "/proc/net/route"
+	ipv6Path = "/proc/net/ipv6_route"
+
+	// Flags from linux/route.h
+	rtfUp     = 0x0001
+	rtfReject = 0x0200
+)
+
+// ErrNoRoute is returned when there is no default route.
+var ErrNoRoute = errors.New("no default route")
+
+// Route is a single default route.
+type Route struct {
+	Interface string
+	Metric    uint32
+	IPv6      bool
+}
+
+// GetDefaultRoutes returns every default route, IPv4 and IPv6,
+// lowest metric first. IPv4 wins when the metrics are equal.
+// A missing ipv6_route file is not an error.
+func GetDefaultRoutes() ([]*Route, error) {
+	var routes []*Route
+
+	file, err := os.Open(ipv4Path)
+	if err != nil {
+		return nil, err
+	}
+	ipv4, err := parseIPv4(file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	routes = append(routes, ipv4...)
+
+	file, err = os.Open(ipv6Path)
+	if err == nil {
+		ipv6, err := parseIPv6(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, ipv6...)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Metric < routes[j].Metric
+	})
+
+	return routes, nil
+}
+
+// GetDefaultInterface returns the interface of the best default route.
+func GetDefaultInterface() (string, error) {
+	routes, err := GetDefaultRoutes()
+	if err != nil {
+		return "", err
+	}
+
+	if len(routes) == 0 {
+		return "", ErrNoRoute
+	}
+
+	return routes[0].Interface, nil
+}
+
+// parseIPv4 reads the default routes from /proc/net/route. After a
+// header each line has the fields
+//
+//	Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+//
+// with the addresses and flags in hex.
+func parseIPv4(reader io.Reader) ([]*Route, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	var routes []*Route
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 || fields[0] == "Iface" {
+			continue
+		}
+
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		if flags&rtfUp == 0 || flags&rtfReject != 0 {
+			continue
+		}
+
+		metric, err := strconv.ParseUint(fields[6], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, &Route{
+			Interface: fields[0],
+			Metric:    uint32(metric),
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
+
+// parseIPv6 reads the default routes from /proc/net/ipv6_route.
+// There is no header, each line has the fields
+//
+//	dest dest_len src src_len next_hop metric refcnt use flags iface
+//
+// with everything but the interface in hex.
+func parseIPv6(reader io.Reader) ([]*Route, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	var routes []*Route
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		if strings.Trim(fields[0], "0") != "" || fields[1] != "00" {
+			continue
+		}
+
+		flags, err := strconv.ParseUint(fields[8], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		// The kernel adds unreachable defaults on lo
+		if flags&rtfUp == 0 || flags&rtfReject != 0 || fields[9] == "lo" {
+			continue
+		}
+
+		metric, err := strconv.ParseUint(fields[5], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+
+		routes = append(routes, &Route{
+			Interface: fields[9],
+			Metric:    uint32(metric),
+			IPv6:      true,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,157 @@
+/*
+swap implements functions for reading compressed swap statistics.
+zram devices are read from /sys/block/zramN/mm_stat and zswap from
+/sys/kernel/debug/zswap, falling back on /proc/meminfo when debugfs
+can't be read. Swap used/total is found in memory.MemInfo.
+
+	devices, err := swap.GetZram()
+	// .. handle error
+	for _, d := range devices {
+		fmt.Println(d.Name, d.Ratio())
+	}
+
+All sizes are uint64 and the units are bytes.
+*/
+package swap
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jameswelchman/clark/pkg/memory"
+	"github.com/jameswelchman/clark/pkg/sysfs"
+)
+
+const (
+	zramPath        = "/sys/block"
+	zswapDebugPath  = "/sys/kernel/debug/zswap"
+	zswapModulePath = "/sys/module/zswap/parameters"
+)
+
+type (
+	// Zram is a single read of a zram device.
+	Zram struct {
+		Name string
+
+		// OrigData is the uncompressed size of the stored data,
+		// ComprData is its compressed size and MemUsed is the memory
+		// used by the device including fragmentation.
+		OrigData  uint64
+		ComprData uint64
+		MemUsed   uint64
+	}
+
+	// Zswap is a single read of the zswap pool.
+	Zswap struct {
+		Enabled bool
+
+		// PoolSize is the memory used by the compressed pool and
+		// Stored is the uncompressed size of the pages it holds.
+		PoolSize uint64
+		Stored   uint64
+	}
+)
+
+// Ratio returns the compression ratio of the device.
+// We return zero for an empty device.
+func (z *Zram) Ratio() float64 {
+	if z.ComprData == 0 {
+		return 0
+	}
+	return float64(z.OrigData) / float64(z.ComprData)
+}
+
+// Ratio returns the compression ratio of the pool.
+// We return zero for an empty pool.
+func (z *Zswap) Ratio() float64 {
+	if z.PoolSize == 0 {
+		return 0
+	}
+	return float64(z.Stored) / float64(z.PoolSize)
+}
+
+// GetZram reads every zram device which has an mm_stat file.
+// Devices which haven't been initialised are skipped.
+func GetZram() ([]*Zram, error) {
+	paths, err := filepath.Glob(zramPath + "/zram*/mm_stat")
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []*Zram
+	for _, p := range paths {
+		mmStat, err := sysfs.ReadString(p)
+		if err != nil {
+			continue
+		}
+
+		z, err := parseMmStat(mmStat)
+		if err != nil {
+			return nil, err
+		}
+
+		z.Name = filepath.Base(filepath.Dir(p))
+		devices = append(devices, z)
+	}
+
+	return devices, nil
+}
+
+// parseMmStat parses the contents of mm_stat, whose first three
+// fields are orig_data_size, compr_data_size and mem_used_total.
+func parseMmStat(mmStat string) (*Zram, error) {
+	fields := strings.Fields(mmStat)
+	if len(fields) < 3 {
+		return nil, errors.New("not enough fields in mm_stat")
+	}
+
+	var sizes [3]uint64
+	for i := range sizes {
+		var err error
+		sizes[i], err = strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Zram{
+		OrigData:  sizes[0],
+		ComprData: sizes[1],
+		MemUsed:   sizes[2],
+	}, nil
+}
+
+// GetZswap reads the zswap pool. debugfs is usually only readable by
+// root, so when it can't be read we use the Zswap and Zswapped keys
+// of info, which newer kernels provide. We return nil when zswap is
+// not built into the kernel.
+func GetZswap(info *memory.MemInfo) *Zswap {
+	enabled, err := sysfs.ReadString(zswapModulePath + "/enabled")
+	if err != nil {
+		return nil
+	}
+
+	z := &Zswap{
+		Enabled:  enabled == "Y",
+		PoolSize: info.Zswap,
+		Stored:   info.Zswapped,
+	}
+
+	poolSize, err := sysfs.ReadUint(zswapDebugPath + "/pool_total_size")
+	if err != nil {
+		return z
+	}
+
+	storedPages, err := sysfs.ReadUint(zswapDebugPath + "/stored_pages")
+	if err != nil {
+		return z
+	}
+
+	z.PoolSize = poolSize
+	z.Stored = storedPages * uint64(os.Getpagesize())
+
+	return z
+}
@@ -0,0 +1,42 @@
+/*
+sysfs implements functions for reading files which hold a single
+value, such as /sys/class/hwmon/hwmon0/temp1_input or
+/sys/fs/cgroup/memory.max. Surrounding whitespace is trimmed.
+
+	temp, err := sysfs.ReadFloat(hwmonPath + "temp1_input")
+	// .. handle error
+*/
+package sysfs
+
+import (
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// ReadString reads the value in filePath.
+func ReadString(filePath string) (string, error) {
+	raw, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// ReadFloat reads the number in filePath.
+func ReadFloat(filePath string) (float64, error) {
+	raw, err := ReadString(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(raw, 64)
+}
+
+// ReadUint reads the unsigned integer in filePath.
+func ReadUint(filePath string) (uint64, error) {
+	raw, err := ReadString(filePath)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(raw, 10, 64)
+}
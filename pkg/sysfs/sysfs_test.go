@@ -0,0 +1,30 @@
+package sysfs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, contents string) string {
+	filePath := filepath.Join(t.TempDir(), "value")
+	if err := ioutil.WriteFile(filePath, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestRead(t *testing.T) {
+	if s, err := ReadString(writeFile(t, "powersave\n")); err != nil || s != "powersave" {
+		t.Errorf("ReadString got %q, %v", s, err)
+	}
+	if f, err := ReadFloat(writeFile(t, " 4500000\n")); err != nil || f != 4500000 {
+		t.Errorf("ReadFloat got %v, %v", f, err)
+	}
+	if u, err := ReadUint(writeFile(t, "18446744073709551615\n")); err != nil || u != 1<<64-1 {
+		t.Errorf("ReadUint got %v, %v", u, err)
+	}
+	if _, err := ReadFloat(writeFile(t, "N/A\n")); err == nil {
+		t.Error("ReadFloat parsed N/A")
+	}
+}
@@ -0,0 +1,158 @@
+/*
+uevent implements a listener for kernel kobject uevents over a
+NETLINK_KOBJECT_UEVENT socket. The kernel sends one whenever a device
+is added, removed or changes, such as a charger being plugged in.
+
+	watcher, err := uevent.Watch("power_supply")
+	// .. handle error
+	defer watcher.Close()
+	for event := range watcher.Events {
+		fmt.Println(event.Action, event.Env["POWER_SUPPLY_NAME"])
+	}
+
+When the socket's buffer overflows, such as after the burst of events
+from docking, the lost events are replaced by one with Action Resync.
+Events is closed if reading from the socket fails.
+*/
+package uevent
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+// The kernel broadcasts uevents to this multicast group.
+// udev rebroadcasts them to group 2 with its own header.
+const kernelGroup = 1
+
+// Resync is the Action of the event sent in place of events which
+// were lost. Anything may have changed, so the state should be re-read.
+const Resync = "resync"
+
+// Event is a single uevent.
+type Event struct {
+	// Action is add, remove, change, bind, unbind, online or offline
+	Action    string
+	DevPath   string
+	Subsystem string
+
+	// Env holds every KEY=value pair, including the ones above
+	Env map[string]string
+}
+
+// Watcher sends events for a single subsystem on Events.
+type Watcher struct {
+	Events <-chan *Event
+
+	sub  *netlink.Subscription
+	done chan struct{}
+}
+
+// Watch opens a uevent socket and starts a goroutine which sends every
+// event for subsystem on the returned Watcher. An empty subsystem
+// matches every event. The caller must call Close when done.
+func Watch(subsystem string) (*Watcher, error) {
+	sub, err := netlink.Subscribe(syscall.NETLINK_KOBJECT_UEVENT, kernelGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan *Event, 16)
+	w := &Watcher{
+		Events: events,
+		sub:    sub,
+		done:   make(chan struct{}),
+	}
+
+	go w.read(subsystem, events)
+	return w, nil
+}
+
+// Close stops the watcher.
+func (w *Watcher) Close() {
+	close(w.done)
+	w.sub.Close()
+}
+
+func (w *Watcher) read(subsystem string, events chan<- *Event) {
+	defer close(events)
+
+	for {
+		var event *Event
+		msg, err := w.sub.Receive()
+		if err == netlink.ErrOverflow {
+			// The kernel dropped events, the socket is still fine
+			event = &Event{Action: Resync, Subsystem: subsystem, Env: map[string]string{}}
+		} else if err != nil {
+			return
+		} else {
+			event = accept(msg, subsystem)
+		}
+
+		if event == nil {
+			continue
+		}
+
+		select {
+		case events <- event:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// accept parses a message from the kernel, returning nil for
+// messages which can't be parsed or are for another subsystem.
+func accept(msg []byte, subsystem string) *Event {
+	event, err := parseEvent(msg)
+	if err != nil {
+		return nil
+	}
+
+	if subsystem != "" && event.Subsystem != subsystem {
+		return nil
+	}
+
+	return event
+}
+
+// parseEvent parses a kernel uevent message. These are a header of
+// "action@devpath" followed by KEY=value pairs, all NUL terminated.
+func parseEvent(msg []byte) (*Event, error) {
+	fields := bytes.Split(bytes.TrimRight(msg, "\x00"), []byte{0})
+	if len(fields) < 2 {
+		return nil, errors.New("uevent too short")
+	}
+
+	header := string(fields[0])
+	at := strings.IndexByte(header, '@')
+	if at < 0 {
+		return nil, fmt.Errorf("bad uevent header %q", header)
+	}
+
+	event := &Event{
+		Action:  header[:at],
+		DevPath: header[at+1:],
+		Env:     map[string]string{},
+	}
+
+	for _, f := range fields[1:] {
+		kv := strings.SplitN(string(f), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		event.Env[kv[0]] = kv[1]
+	}
+
+	if action, ok := event.Env["ACTION"]; ok {
+		event.Action = action
+	}
+	event.Subsystem = event.Env["SUBSYSTEM"]
+
+	return event, nil
+}
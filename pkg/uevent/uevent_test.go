@@ -0,0 +1,145 @@
+package uevent
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// payload joins fields with the NUL separators the kernel sends.
+func payload(fields ...string) []byte {
+	return []byte(strings.Join(fields, "\x00") + "\x00")
+}
+
+// Captured from a laptop as its charger was unplugged
+var acChange = payload(
+	"change@/devices/LNXSYSTM:00/LNXSYBUS:00/ACPI0003:00/power_supply/AC",
+	"ACTION=change",
+	"DEVPATH=/devices/LNXSYSTM:00/LNXSYBUS:00/ACPI0003:00/power_supply/AC",
+	"SUBSYSTEM=power_supply",
+	"POWER_SUPPLY_NAME=AC",
+	"POWER_SUPPLY_TYPE=Mains",
+	"POWER_SUPPLY_ONLINE=0",
+	"SEQNUM=4242",
+)
+
+func TestParseChange(t *testing.T) {
+	event := accept(acChange, "power_supply")
+	if event == nil {
+		t.Fatal("the AC change event was rejected")
+	}
+
+	if event.Action != "change" ||
+		event.DevPath != "/devices/LNXSYSTM:00/LNXSYBUS:00/ACPI0003:00/power_supply/AC" ||
+		event.Subsystem != "power_supply" ||
+		event.Env["POWER_SUPPLY_NAME"] != "AC" ||
+		event.Env["POWER_SUPPLY_ONLINE"] != "0" {
+		t.Errorf("got %+v", event)
+	}
+}
+
+func TestParseAddRemove(t *testing.T) {
+	for _, action := range []string{"add", "remove"} {
+		devPath := "/devices/LNXSYSTM:00/LNXSYBUS:00/PNP0C0A:00/power_supply/BAT1"
+		msg := payload(
+			action+"@"+devPath,
+			"ACTION="+action,
+			"DEVPATH="+devPath,
+			"SUBSYSTEM=power_supply",
+			"POWER_SUPPLY_NAME=BAT1",
+			"SEQNUM=4243",
+		)
+
+		event := accept(msg, "power_supply")
+		if event == nil {
+			t.Fatalf("the %s event was rejected", action)
+		}
+		if event.Action != action || event.DevPath != devPath || event.Env["POWER_SUPPLY_NAME"] != "BAT1" {
+			t.Errorf("%s: got %+v", action, event)
+		}
+	}
+}
+
+func TestRejectLibudev(t *testing.T) {
+	// udev's rebroadcast has a binary header after "libudev"
+	msg := append([]byte("libudev\x00\xfe\xed\xca\xfe"), acChange...)
+
+	if event := accept(msg, "power_supply"); event != nil {
+		t.Errorf("accepted a libudev message: %+v", event)
+	}
+}
+
+func TestFilterSubsystem(t *testing.T) {
+	msg := payload(
+		"change@/devices/virtual/net/wlan0",
+		"ACTION=change",
+		"DEVPATH=/devices/virtual/net/wlan0",
+		"SUBSYSTEM=net",
+		"INTERFACE=wlan0",
+		"SEQNUM=4244",
+	)
+
+	if event := accept(msg, "power_supply"); event != nil {
+		t.Errorf("a net event passed the power_supply filter: %+v", event)
+	}
+	if event := accept(msg, ""); event == nil {
+		t.Error("an empty subsystem should match every event")
+	}
+}
+
+func TestParseBad(t *testing.T) {
+	for _, msg := range [][]byte{
+		nil,
+		payload("change@/devices/foo"),
+		payload("no at sign", "ACTION=change"),
+	} {
+		if _, err := parseEvent(msg); err == nil {
+			t.Errorf("%q: expected an error", msg)
+		}
+	}
+}
+
+func openFds(t *testing.T) int {
+	fds, err := ioutil.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skipf("can't count open files :: %v", err)
+	}
+	return len(fds)
+}
+
+func TestWatcherCloseWhileFull(t *testing.T) {
+	before := openFds(t)
+
+	w, err := Watch("net")
+	if err != nil {
+		t.Skipf("can't watch uevents :: %v", err)
+	}
+
+	// Nothing reads Events, so the reader ends up blocked sending.
+	// Writing to uevent makes the kernel send a change event.
+	for i := 0; i <= cap(w.Events); i++ {
+		err := ioutil.WriteFile("/sys/class/net/lo/uevent", []byte("change"), 0644)
+		if err != nil {
+			w.Close()
+			t.Skipf("can't trigger a uevent :: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for len(w.Events) < cap(w.Events) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(w.Events) < cap(w.Events) {
+		w.Close()
+		t.Fatalf("only %d of %d events arrived", len(w.Events), cap(w.Events))
+	}
+
+	w.Close()
+	for range w.Events {
+	}
+
+	if after := openFds(t); after != before {
+		t.Errorf("%d files open after Close, want %d", after, before)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+units implements human readable formatting of byte counts and rates.
+Values are scaled to the largest unit which keeps the number at or
+above one.
+
+	units.IEC.Bytes(1536)         // "1.5 KiB"
+	units.SI.Bytes(1536)          // "1.5 kB"
+	units.SI.BitRate(12000000)    // "12.0 Mb/s"
+	units.SI.ByteRate(1500000)    // "1.5 MB/s"
+
+Setting Fixed pads the output to the widest value the format can
+produce, so text on the bar doesn't jitter as the value changes.
+
+	f := units.Format{System: units.Decimal, Precision: 2, Fixed: true}
+	f.BitRate(12000000)           // " 12.00 Mb/s"
+*/
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// System chooses between powers of 1000 and powers of 1024.
+// Bytes and bit rates both follow it.
+type System int
+
+const (
+	// Decimal scales by 1000 with the SI units kB, MB, GB...
+	Decimal System = iota
+
+	// Binary scales by 1024 with the IEC units KiB, MiB, GiB...
+	Binary
+)
+
+var (
+	siBytes  = []string{"B", "kB", "MB", "GB", "TB", "PB"}
+	iecBytes = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	siBits   = []string{"b/s", "kb/s", "Mb/s", "Gb/s", "Tb/s"}
+	iecBits  = []string{"b/s", "Kib/s", "Mib/s", "Gib/s", "Tib/s"}
+	siRates  = []string{"B/s", "kB/s", "MB/s", "GB/s", "TB/s", "PB/s"}
+	iecRates = []string{"B/s", "KiB/s", "MiB/s", "GiB/s", "TiB/s", "PiB/s"}
+)
+
+// Format describes how a value is printed.
+type Format struct {
+	System System
+
+	// Precision is the number of digits after the decimal point.
+	Precision int
+
+	// Fixed pads the number and the unit to a constant width.
+	Fixed bool
+}
+
+// Formats which are used often enough to be worth naming
+var (
+	SI  = Format{System: Decimal, Precision: 1}
+	IEC = Format{System: Binary, Precision: 1}
+)
+
+func (f Format) base() float64 {
+	if f.System == Binary {
+		return 1024
+	}
+	return 1000
+}
+
+// Bytes formats a number of bytes.
+func (f Format) Bytes(bytes float64) string {
+	if f.System == Binary {
+		return f.format(bytes, iecBytes)
+	}
+	return f.format(bytes, siBytes)
+}
+
+// ByteRate formats a number of bytes per second.
+func (f Format) ByteRate(bytesPerSecond float64) string {
+	if f.System == Binary {
+		return f.format(bytesPerSecond, iecRates)
+	}
+	return f.format(bytesPerSecond, siRates)
+}
+
+// BitRate formats a number of bits per second.
+func (f Format) BitRate(bitsPerSecond float64) string {
+	if f.System == Binary {
+		return f.format(bitsPerSecond, iecBits)
+	}
+	return f.format(bitsPerSecond, siBits)
+}
+
+// format scales value by the base of f until it fits in one of
+// names. We compare after rounding, so 999.96 with a precision of
+// one becomes "1.0 kB" rather than "1000.0 B".
+func (f Format) format(value float64, names []string) string {
+	base := f.base()
+	precision := math.Pow(10, float64(f.Precision))
+
+	negative := value < 0
+	value = math.Abs(value)
+
+	i := 0
+	for i < len(names)-1 && math.Round(value*precision)/precision >= base {
+		value /= base
+		i++
+	}
+
+	if negative {
+		value = -value
+	}
+
+	if !f.Fixed {
+		return fmt.Sprintf("%.*f %s", f.Precision, value, names[i])
+	}
+
+	// The widest number is base-1 with the requested precision
+	width := len(fmt.Sprintf("%.*f", f.Precision, base-1))
+	unitWidth := 0
+	for _, n := range names {
+		if len(n) > unitWidth {
+			unitWidth = len(n)
+		}
+	}
+
+	unit := names[i] + strings.Repeat(" ", unitWidth-len(names[i]))
+	return fmt.Sprintf("%*.*f %s", width, f.Precision, value, unit)
+}
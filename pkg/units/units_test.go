@@ -0,0 +1,49 @@
+package units
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	fixed := Format{System: Decimal, Precision: 2, Fixed: true}
+	fixedIEC := Format{System: Binary, Precision: 1, Fixed: true}
+
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{IEC.Bytes(1536), "1.5 KiB"},
+		{SI.Bytes(1536), "1.5 kB"},
+		{SI.Bytes(0), "0.0 B"},
+		{SI.Bytes(-2500), "-2.5 kB"},
+		{SI.BitRate(12000000), "12.0 Mb/s"},
+		{SI.ByteRate(1500000), "1.5 MB/s"},
+		{IEC.ByteRate(1536), "1.5 KiB/s"},
+
+		// Rounding carries into the next unit
+		{SI.Bytes(999.96), "1.0 kB"},
+
+		// Fixed pads the number and the whole unit
+		{fixed.BitRate(12000000), " 12.00 Mb/s"},
+		{fixed.BitRate(5), "  5.00 b/s "},
+		{fixed.ByteRate(5), "  5.00 B/s "},
+		{fixed.ByteRate(1500000), "  1.50 MB/s"},
+		{fixedIEC.ByteRate(1536), "   1.5 KiB/s"},
+		{fixedIEC.ByteRate(5), "   5.0 B/s  "},
+		{fixedIEC.Bytes(5), "   5.0 B  "},
+	}
+
+	for i, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%d: got %q, want %q", i, test.got, test.want)
+		}
+	}
+}
+
+func TestFixedWidth(t *testing.T) {
+	f := Format{System: Binary, Precision: 2, Fixed: true}
+	width := len(f.ByteRate(0))
+	for _, v := range []float64{1, 999, 1023, 1024, 5e6, 7e9, 1e15} {
+		if got := f.ByteRate(v); len(got) != width {
+			t.Errorf("ByteRate(%v) = %q is %d wide, want %d", v, got, len(got), width)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+/*
+vpn implements functions for finding VPN interfaces in /sys/class/net.
+tun and tap devices are found by their tun_flags, WireGuard devices by
+the DEVTYPE in their uevent file.
+
+	ifaces, err := vpn.GetInterfaces()
+	// .. handle error
+	for _, iface := range ifaces {
+		fmt.Println(iface.Name, iface.Kind, iface.Up)
+	}
+*/
+package vpn
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jameswelchman/clark/pkg/linkstate"
+)
+
+const (
+	netPath = "/sys/class/net/"
+
+	// From linux/if_tun.h
+	iffTun = 0x0001
+	iffTap = 0x0002
+)
+
+// Kind is the type of a VPN interface.
+type Kind string
+
+const (
+	Tun       Kind = "tun"
+	Tap       Kind = "tap"
+	WireGuard Kind = "wireguard"
+)
+
+// Interface is a single VPN interface.
+type Interface struct {
+	Name string
+	Kind Kind
+	Up   bool
+}
+
+// GetInterfaces returns every VPN interface, up or not, sorted by name.
+func GetInterfaces() ([]*Interface, error) {
+	return findInterfaces(netPath)
+}
+
+func findInterfaces(dir string) ([]*Interface, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces []*Interface
+	for _, entry := range entries {
+		kind, ok := interfaceKind(dir + entry.Name())
+		if !ok {
+			continue
+		}
+
+		state, _ := linkstate.Get(entry.Name())
+		ifaces = append(ifaces, &Interface{
+			Name: entry.Name(),
+			Kind: kind,
+			Up:   state.LinkUp(),
+		})
+	}
+
+	sort.Slice(ifaces, func(i, j int) bool {
+		return ifaces[i].Name < ifaces[j].Name
+	})
+
+	return ifaces, nil
+}
+
+// interfaceKind returns the kind of the interface at path, or false
+// if it isn't a VPN.
+func interfaceKind(path string) (Kind, bool) {
+	b, err := ioutil.ReadFile(path + "/tun_flags")
+	if err == nil {
+		flags, err := strconv.ParseUint(strings.TrimSpace(string(b)), 0, 32)
+		if err != nil {
+			return "", false
+		}
+		if flags&iffTap != 0 {
+			return Tap, true
+		}
+		if flags&iffTun != 0 {
+			return Tun, true
+		}
+		return "", false
+	}
+
+	file, err := os.Open(path + "/uevent")
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	if parseDevType(file) == "wireguard" {
+		return WireGuard, true
+	}
+	return "", false
+}
+
+// parseDevType finds DEVTYPE in a uevent file.
+func parseDevType(reader io.Reader) string {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), "DEVTYPE="); value != scanner.Text() {
+			return value
+		}
+	}
+
+	return ""
+}
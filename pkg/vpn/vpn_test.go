@@ -0,0 +1,70 @@
+package vpn
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeIface creates dir/name with the given files.
+func writeIface(t *testing.T, dir, name string, files map[string]string) {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for file, contents := range files {
+		if err := ioutil.WriteFile(filepath.Join(path, file), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindInterfaces(t *testing.T) {
+	dir := t.TempDir() + "/"
+
+	// IFF_TUN and IFF_TAP with IFF_NO_PI
+	writeIface(t, dir, "testtun0", map[string]string{"tun_flags": "0x1001\n", "uevent": "INTERFACE=testtun0\nIFINDEX=7\n"})
+	writeIface(t, dir, "testtap0", map[string]string{"tun_flags": "0x1002\n", "uevent": "INTERFACE=testtap0\nIFINDEX=8\n"})
+	writeIface(t, dir, "testwg0", map[string]string{"uevent": "DEVTYPE=wireguard\nINTERFACE=testwg0\nIFINDEX=9\n"})
+	writeIface(t, dir, "testeth0", map[string]string{"uevent": "INTERFACE=testeth0\nIFINDEX=2\n"})
+	writeIface(t, dir, "testbr0", map[string]string{"uevent": "DEVTYPE=bridge\nINTERFACE=testbr0\nIFINDEX=3\n"})
+	writeIface(t, dir, "testlo", nil)
+
+	ifaces, err := findInterfaces(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, iface := range ifaces {
+		got = append(got, iface.Name+" "+string(iface.Kind))
+	}
+	want := "testtap0 tap, testtun0 tun, testwg0 wireguard"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, ", "), want)
+	}
+}
+
+func TestInterfaceKindBadFlags(t *testing.T) {
+	dir := t.TempDir() + "/"
+	writeIface(t, dir, "bad", map[string]string{"tun_flags": "garbage\n"})
+	writeIface(t, dir, "neither", map[string]string{"tun_flags": "0x1000\n"})
+
+	for _, name := range []string{"bad", "neither"} {
+		if kind, ok := interfaceKind(dir + name); ok {
+			t.Errorf("%s is %s, want no VPN", name, kind)
+		}
+	}
+}
+
+func TestParseDevType(t *testing.T) {
+	uevent := "DEVTYPE=wireguard\nINTERFACE=wg0\nIFINDEX=5\n"
+	if got := parseDevType(strings.NewReader(uevent)); got != "wireguard" {
+		t.Errorf("got %q, want wireguard", got)
+	}
+	if got := parseDevType(strings.NewReader("INTERFACE=eth0\nIFINDEX=2\n")); got != "" {
+		t.Errorf("got %q without DEVTYPE, want empty", got)
+	}
+}
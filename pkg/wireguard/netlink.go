@@ -0,0 +1,141 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"syscall"
+	"time"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+// From linux/wireguard.h
+const (
+	wgCmdGetDevice = 0
+
+	wgDeviceAIfname = 2
+	wgDeviceAPeers  = 8
+
+	wgPeerAPublicKey         = 1
+	wgPeerALastHandshakeTime = 6
+	wgPeerARxBytes           = 7
+	wgPeerATxBytes           = 8
+)
+
+// open dials generic netlink and resolves the wireguard family if
+// we haven't already. Without the family we switch to wg for good.
+func (c *Client) open() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	conn, err := netlink.Dial(syscall.NETLINK_GENERIC)
+	if err != nil {
+		c.useWg = true
+		return err
+	}
+
+	family, err := conn.ResolveFamily("wireguard")
+	if err == syscall.ENOENT {
+		conn.Close()
+		c.useWg = true
+		return err
+	} else if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.conn = conn
+	c.family = family
+	return nil
+}
+
+func (c *Client) getNetlinkDevice(device string) (*Device, error) {
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+
+	replies, err := c.conn.Execute(c.family, syscall.NLM_F_DUMP,
+		netlink.GenlMessage(wgCmdGetDevice, 1,
+			[]netlink.Attr{netlink.StringAttr(wgDeviceAIfname, device)}))
+	if err == syscall.EPERM {
+		// wg may be setuid or have the capability when we don't
+		c.Close()
+		c.useWg = true
+		return nil, err
+	} else if err != nil {
+		// Start again with a fresh socket rather
+		// than risk reading stale replies
+		c.Close()
+		return nil, err
+	}
+
+	return parseDevice(replies, device)
+}
+
+func parseDevice(replies [][]byte, device string) (*Device, error) {
+	d := &Device{Name: device}
+	seen := map[string]bool{}
+	for _, reply := range replies {
+		_, attrs, err := netlink.ParseGenl(reply)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range attrs {
+			if a.Type != wgDeviceAPeers {
+				continue
+			}
+
+			// A list of peers, each nested under its index
+			peers, err := a.Nested()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, peer := range peers {
+				p, err := parsePeer(peer)
+				if err != nil {
+					return nil, err
+				}
+				if seen[p.PublicKey] {
+					continue
+				}
+				seen[p.PublicKey] = true
+				d.Peers = append(d.Peers, p)
+			}
+		}
+	}
+
+	return d, nil
+}
+
+func parsePeer(peer netlink.Attr) (*Peer, error) {
+	attrs, err := peer.Nested()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Peer{}
+	for _, a := range attrs {
+		switch a.Type {
+		case wgPeerAPublicKey:
+			p.PublicKey = base64.StdEncoding.EncodeToString(a.Data)
+		case wgPeerALastHandshakeTime:
+			// A struct __kernel_timespec, two s64
+			if len(a.Data) < 16 {
+				continue
+			}
+			sec := int64(netlink.NativeEndian.Uint64(a.Data[0:8]))
+			nsec := int64(netlink.NativeEndian.Uint64(a.Data[8:16]))
+			if sec != 0 || nsec != 0 {
+				p.LastHandshake = time.Unix(sec, nsec)
+			}
+		case wgPeerARxBytes:
+			p.RxBytes = a.Uint64()
+		case wgPeerATxBytes:
+			p.TxBytes = a.Uint64()
+		}
+	}
+
+	return p, nil
+}
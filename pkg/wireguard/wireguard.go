@@ -0,0 +1,209 @@
+/*
+wireguard implements functions for reading the peers of a WireGuard
+device, using the wireguard generic netlink family. When that can't be
+used we fall back to parsing `wg show <device> dump`. Both need
+CAP_NET_ADMIN.
+
+	device, err := wireguard.GetDevice("wg0")
+	// .. handle error
+	fmt.Println(device.LastHandshake(), device.RxBytes(), device.TxBytes())
+
+A Client keeps its socket open between reads and stops running wg
+so often once it has failed.
+
+Transfer counts are in bytes.
+*/
+package wireguard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+// How long we wait before running wg again after it failed,
+// such as when we don't have CAP_NET_ADMIN
+const wgBackoff = time.Minute
+
+// Peer is a single WireGuard peer.
+type Peer struct {
+	PublicKey     string
+	LastHandshake time.Time
+	RxBytes       uint64
+	TxBytes       uint64
+}
+
+// Device is a WireGuard device and its peers.
+type Device struct {
+	Name  string
+	Peers []*Peer
+}
+
+// LastHandshake returns the most recent handshake with any peer,
+// or the zero time if there has never been one.
+func (d *Device) LastHandshake() time.Time {
+	var last time.Time
+	for _, p := range d.Peers {
+		if p.LastHandshake.After(last) {
+			last = p.LastHandshake
+		}
+	}
+	return last
+}
+
+// RxBytes returns the bytes received from every peer.
+func (d *Device) RxBytes() uint64 {
+	var total uint64
+	for _, p := range d.Peers {
+		total += p.RxBytes
+	}
+	return total
+}
+
+// TxBytes returns the bytes sent to every peer.
+func (d *Device) TxBytes() uint64 {
+	var total uint64
+	for _, p := range d.Peers {
+		total += p.TxBytes
+	}
+	return total
+}
+
+// Client reads WireGuard devices, keeping its netlink socket open
+// between reads. It isn't safe for concurrent use.
+type Client struct {
+	conn   *netlink.Conn
+	family uint16
+
+	// useWg is set once netlink turned out to be missing or not
+	// permitted, we don't try it again
+	useWg bool
+
+	// wgErr is the last failure of wg, which we return rather than
+	// run it again until wgRetry. When wg isn't installed, noWg,
+	// it is returned for good.
+	wgErr   error
+	wgRetry time.Time
+	noWg    bool
+}
+
+// NewClient returns a Client. The socket is opened by the first read.
+func NewClient() *Client {
+	return &Client{}
+}
+
+// Close closes the socket of the client.
+func (c *Client) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// GetDevice returns the peers of device from netlink, or from wg
+// when netlink isn't there or we aren't allowed to use it.
+func (c *Client) GetDevice(device string) (*Device, error) {
+	if !c.useWg {
+		d, err := c.getNetlinkDevice(device)
+		if !c.useWg {
+			return d, err
+		}
+	}
+
+	if c.wgErr != nil && (c.noWg || time.Now().Before(c.wgRetry)) {
+		return nil, c.wgErr
+	}
+
+	d, err := getWgDevice(device)
+	if err != nil {
+		c.wgErr = fmt.Errorf("couldn't run wg :: %v", err)
+		c.wgRetry = time.Now().Add(wgBackoff)
+		c.noWg = errors.Is(err, exec.ErrNotFound)
+		return nil, c.wgErr
+	}
+
+	c.wgErr = nil
+	return d, nil
+}
+
+// GetDevice returns the peers of device from netlink, or from wg.
+// Every call opens a new netlink socket, a Client keeps one open.
+func GetDevice(device string) (*Device, error) {
+	c := NewClient()
+	defer c.Close()
+	return c.GetDevice(device)
+}
+
+func getWgDevice(device string) (*Device, error) {
+	out, err := exec.Command("wg", "show", device, "dump").Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		// wg says why, such as "Operation not permitted"
+		return nil, errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+	} else if err != nil {
+		return nil, err
+	}
+
+	return parseDump(strings.NewReader(string(out)), device)
+}
+
+// parseDump reads the output of `wg show <device> dump`. The first
+// line is the device itself, every other line is a tab separated peer:
+//
+//	public-key preshared-key endpoint allowed-ips latest-handshake transfer-rx transfer-tx persistent-keepalive
+func parseDump(reader io.Reader, device string) (*Device, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	d := &Device{Name: device}
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("empty wg dump")
+	}
+
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 8 {
+			return nil, fmt.Errorf("not enough fields in wg dump line %q", scanner.Text())
+		}
+
+		handshake, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		rx, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		tx, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &Peer{
+			PublicKey: fields[0],
+			RxBytes:   rx,
+			TxBytes:   tx,
+		}
+		if handshake != 0 {
+			p.LastHandshake = time.Unix(handshake, 0)
+		}
+		d.Peers = append(d.Peers, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
@@ -0,0 +1,158 @@
+package wireguard
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+func skipBigEndian(t *testing.T) {
+	if netlink.NativeEndian != binary.LittleEndian {
+		t.Skip("captured messages are little endian")
+	}
+}
+
+const (
+	key1 = "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+	key2 = "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI="
+)
+
+// The payload of a WG_CMD_GET_DEVICE reply for wg0 with two peers,
+// the second has never completed a handshake
+var deviceReply = []byte{
+	// genlmsghdr: WG_CMD_GET_DEVICE, version 1
+	0x00, 0x01, 0x00, 0x00,
+	// WGDEVICE_A_IFINDEX 5
+	0x08, 0x00, 0x01, 0x00, 0x05, 0x00, 0x00, 0x00,
+	// WGDEVICE_A_IFNAME "wg0"
+	0x08, 0x00, 0x02, 0x00, 'w', 'g', '0', 0x00,
+	// WGDEVICE_A_LISTEN_PORT 51820
+	0x06, 0x00, 0x06, 0x00, 0x6c, 0xca, 0x00, 0x00,
+	// WGDEVICE_A_PEERS, nested
+	0xac, 0x00, 0x08, 0x80,
+
+	// peer 0, nested
+	0x54, 0x00, 0x00, 0x80,
+	// WGPEER_A_PUBLIC_KEY
+	0x24, 0x00, 0x01, 0x00,
+	0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+	0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+	0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+	0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01,
+	// WGPEER_A_LAST_HANDSHAKE_TIME 1700000000s 500000000ns
+	0x14, 0x00, 0x06, 0x00,
+	0x00, 0xf1, 0x53, 0x65, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x65, 0xcd, 0x1d, 0x00, 0x00, 0x00, 0x00,
+	// WGPEER_A_RX_BYTES 1048576
+	0x0c, 0x00, 0x07, 0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// WGPEER_A_TX_BYTES 2048
+	0x0c, 0x00, 0x08, 0x00, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+
+	// peer 1, nested
+	0x54, 0x00, 0x01, 0x80,
+	// WGPEER_A_PUBLIC_KEY
+	0x24, 0x00, 0x01, 0x00,
+	0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+	0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+	0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+	0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02,
+	// WGPEER_A_LAST_HANDSHAKE_TIME never
+	0x14, 0x00, 0x06, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// WGPEER_A_RX_BYTES 0
+	0x0c, 0x00, 0x07, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// WGPEER_A_TX_BYTES 148, handshake initiations
+	0x0c, 0x00, 0x08, 0x00, 0x94, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+}
+
+func TestParseDevice(t *testing.T) {
+	skipBigEndian(t)
+
+	d, err := parseDevice([][]byte{deviceReply}, "wg0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Peers) != 2 {
+		t.Fatalf("got %d peers, want 2", len(d.Peers))
+	}
+
+	p := d.Peers[0]
+	handshake := time.Unix(1700000000, 500000000)
+	if p.PublicKey != key1 || !p.LastHandshake.Equal(handshake) || p.RxBytes != 1048576 || p.TxBytes != 2048 {
+		t.Errorf("got peer 0 %+v", p)
+	}
+
+	p = d.Peers[1]
+	if p.PublicKey != key2 || !p.LastHandshake.IsZero() || p.RxBytes != 0 || p.TxBytes != 148 {
+		t.Errorf("got peer 1 %+v", p)
+	}
+
+	if !d.LastHandshake().Equal(handshake) || d.RxBytes() != 1048576 || d.TxBytes() != 2196 {
+		t.Errorf("got totals %v %d %d", d.LastHandshake(), d.RxBytes(), d.TxBytes())
+	}
+}
+
+// A dump split over several messages repeats the device attributes,
+// a peer repeated in the next message is only counted once
+func TestParseDeviceMultipart(t *testing.T) {
+	skipBigEndian(t)
+
+	d, err := parseDevice([][]byte{deviceReply, deviceReply}, "wg0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Peers) != 2 || d.TxBytes() != 2196 {
+		t.Errorf("got %d peers sending %d bytes, want 2 and 2196", len(d.Peers), d.TxBytes())
+	}
+}
+
+func TestParseDeviceBad(t *testing.T) {
+	skipBigEndian(t)
+
+	// Cut off inside the second peer
+	if _, err := parseDevice([][]byte{deviceReply[:len(deviceReply)-20]}, "wg0"); err == nil {
+		t.Error("no error for a truncated reply")
+	}
+	if _, err := parseDevice([][]byte{{0x00, 0x01}}, "wg0"); err == nil {
+		t.Error("no error for a short reply")
+	}
+}
+
+const dump = "cHJpdmF0ZQ==\tcHVibGlj\t51820\toff\n" +
+	key1 + "\t(none)\t192.0.2.1:51820\t10.0.0.0/24\t1700000000\t1048576\t2048\t25\n" +
+	key2 + "\t(none)\t(none)\t10.0.1.0/24\t0\t0\t148\toff\n"
+
+func TestParseDump(t *testing.T) {
+	d, err := parseDump(strings.NewReader(dump), "wg0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Peers) != 2 {
+		t.Fatalf("got %d peers, want 2", len(d.Peers))
+	}
+
+	p := d.Peers[0]
+	if p.PublicKey != key1 || !p.LastHandshake.Equal(time.Unix(1700000000, 0)) || p.RxBytes != 1048576 || p.TxBytes != 2048 {
+		t.Errorf("got peer 0 %+v", p)
+	}
+	p = d.Peers[1]
+	if p.PublicKey != key2 || !p.LastHandshake.IsZero() || p.TxBytes != 148 {
+		t.Errorf("got peer 1 %+v", p)
+	}
+}
+
+func TestParseDumpBad(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"device line\n" + key1 + "\t(none)\n",
+		"device line\n" + key1 + "\t(none)\t(none)\t10.0.0.0/24\tsoon\t0\t0\toff\n",
+	} {
+		if _, err := parseDump(strings.NewReader(text), "wg0"); err == nil {
+			t.Errorf("no error for %q", text)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package wireless
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+// From linux/nl80211.h
+const (
+	nl80211CmdGetInterface = 5
+	nl80211CmdGetStation   = 17
+
+	nl80211AttrIfindex   = 3
+	nl80211AttrStaInfo   = 21
+	nl80211AttrWiphyFreq = 38
+	nl80211AttrSsid      = 52
+
+	nl80211StaInfoSignal    = 7
+	nl80211StaInfoTxBitrate = 8
+
+	nl80211RateInfoBitrate   = 1
+	nl80211RateInfoBitrate32 = 5
+
+	// Bitrates are in units of 100kbit/s
+	bitrateUnit = 100000
+)
+
+// Client reads wireless links, keeping its nl80211 socket open
+// between reads. It isn't safe for concurrent use.
+type Client struct {
+	conn   *netlink.Conn
+	family uint16
+
+	// useIw is set once nl80211 turned out to be missing,
+	// we don't look for it again
+	useIw bool
+}
+
+// NewClient returns a Client. The socket is opened by the first read.
+func NewClient() *Client {
+	return &Client{}
+}
+
+// Close closes the socket of the client.
+func (c *Client) Close() {
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// GetLink returns the state of the link on device, see GetLink.
+func (c *Client) GetLink(device string) (*Link, error) {
+	if !IsWireless(device) {
+		return nil, ErrNotWireless
+	}
+
+	var link *Link
+	var err error
+	if c.useIw {
+		link, err = getIwLink(device)
+	} else {
+		link, err = c.getNl80211Link(device)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	quality, signal, err := readWireless(wirelessPath, device)
+	if err == nil {
+		link.Quality = quality
+		if link.Signal == 0 {
+			link.Signal = signal
+		}
+	} else {
+		link.Quality = signalQuality(link.Signal)
+	}
+
+	return link, nil
+}
+
+// open dials generic netlink and resolves nl80211 if we haven't
+// already. Without nl80211 we switch to iw for good.
+func (c *Client) open() error {
+	if c.conn != nil {
+		return nil
+	}
+
+	conn, err := netlink.Dial(syscall.NETLINK_GENERIC)
+	if err != nil {
+		c.useIw = true
+		return err
+	}
+
+	family, err := conn.ResolveFamily("nl80211")
+	if err == syscall.ENOENT {
+		conn.Close()
+		c.useIw = true
+		return err
+	} else if err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.conn = conn
+	c.family = family
+	return nil
+}
+
+func (c *Client) getNl80211Link(device string) (*Link, error) {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.open(); err != nil {
+		if c.useIw {
+			return getIwLink(device)
+		}
+		return nil, err
+	}
+
+	link, err := c.readLink(device, uint32(iface.Index))
+	if err != nil && err != ErrNotConnected {
+		// Start again with a fresh socket rather
+		// than risk reading stale replies
+		c.Close()
+	}
+	return link, err
+}
+
+func (c *Client) readLink(device string, index uint32) (*Link, error) {
+	ifindex := []netlink.Attr{netlink.Uint32Attr(nl80211AttrIfindex, index)}
+
+	replies, err := c.conn.Execute(c.family, 0,
+		netlink.GenlMessage(nl80211CmdGetInterface, 0, ifindex))
+	if err != nil {
+		return nil, err
+	}
+
+	link := &Link{Device: device}
+	if err := parseInterface(replies, link); err != nil {
+		return nil, err
+	}
+
+	// There is only a station when we are associated
+	replies, err = c.conn.Execute(c.family, syscall.NLM_F_DUMP,
+		netlink.GenlMessage(nl80211CmdGetStation, 0, ifindex))
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := parseStation(replies, link)
+	if err != nil {
+		return nil, err
+	}
+	if !found && link.SSID == "" {
+		return nil, ErrNotConnected
+	}
+
+	return link, nil
+}
+
+// parseInterface fills in the SSID and frequency from the replies
+// to NL80211_CMD_GET_INTERFACE.
+func parseInterface(replies [][]byte, link *Link) error {
+	for _, reply := range replies {
+		_, attrs, err := netlink.ParseGenl(reply)
+		if err != nil {
+			return err
+		}
+
+		for _, a := range attrs {
+			switch a.Type {
+			case nl80211AttrSsid:
+				link.SSID = string(a.Data)
+			case nl80211AttrWiphyFreq:
+				link.Frequency = a.Uint32()
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseStation fills in the signal and tx bitrate from the replies
+// to NL80211_CMD_GET_STATION. It returns false when there is no
+// station, which is the case when we aren't associated.
+func parseStation(replies [][]byte, link *Link) (bool, error) {
+	for _, reply := range replies {
+		_, attrs, err := netlink.ParseGenl(reply)
+		if err != nil {
+			return false, err
+		}
+
+		for _, a := range attrs {
+			if a.Type != nl80211AttrStaInfo {
+				continue
+			}
+
+			info, err := a.Nested()
+			if err != nil {
+				return false, err
+			}
+
+			for _, i := range info {
+				switch i.Type {
+				case nl80211StaInfoSignal:
+					link.Signal = int(int8(i.Uint8()))
+				case nl80211StaInfoTxBitrate:
+					rate, err := parseBitrate(i)
+					if err != nil {
+						return false, err
+					}
+					link.TxBitrate = rate
+				}
+			}
+
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// parseBitrate prefers the 32 bit rate, the 16 bit one overflows
+// on fast links.
+func parseBitrate(a netlink.Attr) (float64, error) {
+	rates, err := a.Nested()
+	if err != nil {
+		return 0, err
+	}
+
+	var rate uint32
+	for _, r := range rates {
+		switch r.Type {
+		case nl80211RateInfoBitrate32:
+			rate = r.Uint32()
+		case nl80211RateInfoBitrate:
+			if rate == 0 {
+				rate = uint32(r.Uint16())
+			}
+		}
+	}
+
+	return float64(rate) * bitrateUnit, nil
+}
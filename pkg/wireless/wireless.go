@@ -0,0 +1,239 @@
+/*
+wireless implements functions for reading the state of a wireless link:
+the quality and signal level from /proc/net/wireless, and the SSID,
+frequency and tx bitrate from nl80211. When the kernel has no nl80211
+we fall back to the output of `iw dev <device> link`.
+
+	link, err := wireless.GetLink("wlan0")
+	if err == wireless.ErrNotConnected {
+		// not associated with an access point
+	}
+	fmt.Println(link.SSID, link.Quality, link.Band())
+
+Reading a link every second is cheaper with a Client, which keeps
+its socket open and remembers whether nl80211 is there.
+
+	client := wireless.NewClient()
+	defer client.Close()
+	link, err := client.GetLink("wlan0")
+
+Frequencies are in MHz, signal levels in dBm, bitrates in bits per
+second and quality is a percentage.
+*/
+package wireless
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	wirelessPath = "/proc/net/wireless"
+	netPath      = "/sys/class/net/"
+
+	// The link quality in /proc/net/wireless is out of 70
+	// for almost every driver.
+	maxQuality = 70
+)
+
+var (
+	// ErrNotWireless is returned for devices which aren't wireless.
+	ErrNotWireless = errors.New("not a wireless device")
+	// ErrNotConnected is returned when the device isn't associated.
+	ErrNotConnected = errors.New("not connected")
+)
+
+// Link is the state of a wireless link.
+type Link struct {
+	Device    string
+	SSID      string
+	Frequency uint32
+	Signal    int
+	TxBitrate float64
+	Quality   float64
+}
+
+// Band returns the band of the link, such as "2.4GHz".
+func (l *Link) Band() string {
+	switch {
+	case l.Frequency == 0:
+		return ""
+	case l.Frequency < 3000:
+		return "2.4GHz"
+	case l.Frequency < 5925:
+		return "5GHz"
+	case l.Frequency < 7200:
+		return "6GHz"
+	default:
+		return "60GHz"
+	}
+}
+
+// IsWireless returns true when device is a wireless device.
+func IsWireless(device string) bool {
+	_, err := os.Stat(netPath + device + "/wireless")
+	if err == nil {
+		return true
+	}
+
+	_, err = os.Stat(netPath + device + "/phy80211")
+	return err == nil
+}
+
+// GetLink returns the state of the link on device.
+// The SSID, frequency and bitrate come from nl80211 or iw, the
+// quality from /proc/net/wireless. When /proc/net/wireless doesn't
+// list the device the quality is estimated from the signal level.
+//
+// Every call opens a new netlink socket, a Client keeps one open.
+func GetLink(device string) (*Link, error) {
+	c := NewClient()
+	defer c.Close()
+	return c.GetLink(device)
+}
+
+// signalQuality maps a signal level onto a percentage the same way
+// NetworkManager does, -100dBm is 0% and -50dBm is 100%.
+func signalQuality(signal int) float64 {
+	quality := float64(2 * (signal + 100))
+	if quality < 0 {
+		return 0
+	} else if quality > 100 {
+		return 100
+	}
+	return quality
+}
+
+func readWireless(filePath, device string) (float64, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	return parseWireless(file, device)
+}
+
+// parseWireless finds the link quality and signal level of device.
+// The lines look like:
+//
+//	wlan0: 0000   70.  -40.  -256        0      0      0      0      0        0
+func parseWireless(reader io.Reader, device string) (float64, int, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) != device {
+			continue
+		}
+
+		fields := strings.Fields(parts[1])
+		if len(fields) < 3 {
+			return 0, 0, fmt.Errorf("not enough fields for %s", device)
+		}
+
+		link, err := strconv.ParseFloat(strings.TrimSuffix(fields[1], "."), 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		level, err := strconv.ParseFloat(strings.TrimSuffix(fields[2], "."), 64)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		quality := link * 100 / maxQuality
+		if quality > 100 {
+			quality = 100
+		}
+
+		return quality, int(level), nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	return 0, 0, fmt.Errorf("%s not in %s", device, wirelessPath)
+}
+
+func getIwLink(device string) (*Link, error) {
+	out, err := exec.Command("iw", "dev", device, "link").Output()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't run iw :: %v", err)
+	}
+
+	return parseIw(strings.NewReader(string(out)), device)
+}
+
+// parseIw reads the output of `iw dev <device> link` which looks like:
+//
+//	Connected to 00:11:22:33:44:55 (on wlan0)
+//		SSID: HomeNet
+//		freq: 5180
+//		signal: -40 dBm
+//		tx bitrate: 433.3 MBit/s VHT-MCS 9 80MHz short GI VHT-NSS 1
+func parseIw(reader io.Reader, device string) (*Link, error) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Split(bufio.ScanLines)
+
+	link := &Link{Device: device}
+	connected := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "Connected to") {
+			connected = true
+			continue
+		}
+
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch parts[0] {
+		case "SSID":
+			link.SSID = value
+		case "freq":
+			freq, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, err
+			}
+			link.Frequency = uint32(freq)
+		case "signal":
+			signal, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			link.Signal = signal
+		case "tx bitrate":
+			rate, err := strconv.ParseFloat(fields[0], 64)
+			if err != nil {
+				return nil, err
+			}
+			link.TxBitrate = rate * 1e6
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if !connected {
+		return nil, ErrNotConnected
+	}
+
+	return link, nil
+}
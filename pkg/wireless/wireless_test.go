@@ -0,0 +1,213 @@
+package wireless
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/jameswelchman/clark/pkg/netlink"
+)
+
+func skipBigEndian(t *testing.T) {
+	if netlink.NativeEndian != binary.LittleEndian {
+		t.Skip("captured messages are little endian")
+	}
+}
+
+// The payload of an NL80211_CMD_NEW_INTERFACE reply to GET_INTERFACE
+var interfaceReply = []byte{
+	// genlmsghdr: NL80211_CMD_NEW_INTERFACE, version 1
+	0x07, 0x01, 0x00, 0x00,
+	// NL80211_ATTR_IFINDEX 3
+	0x08, 0x00, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00,
+	// NL80211_ATTR_IFNAME "wlan0"
+	0x0a, 0x00, 0x04, 0x00, 'w', 'l', 'a', 'n', '0', 0x00, 0x00, 0x00,
+	// NL80211_ATTR_WIPHY 0
+	0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00,
+	// NL80211_ATTR_IFTYPE station
+	0x08, 0x00, 0x05, 0x00, 0x02, 0x00, 0x00, 0x00,
+	// NL80211_ATTR_WIPHY_FREQ 5180
+	0x08, 0x00, 0x26, 0x00, 0x3c, 0x14, 0x00, 0x00,
+	// NL80211_ATTR_SSID "HomeNet"
+	0x0b, 0x00, 0x34, 0x00, 'H', 'o', 'm', 'e', 'N', 'e', 't', 0x00,
+}
+
+// The payload of an NL80211_CMD_NEW_STATION reply to GET_STATION
+var stationReply = []byte{
+	// genlmsghdr: NL80211_CMD_NEW_STATION, version 1
+	0x13, 0x01, 0x00, 0x00,
+	// NL80211_ATTR_IFINDEX 3
+	0x08, 0x00, 0x03, 0x00, 0x03, 0x00, 0x00, 0x00,
+	// NL80211_ATTR_MAC
+	0x0a, 0x00, 0x06, 0x00, 0x00, 0x11, 0x22, 0x33, 0x44, 0x55, 0x00, 0x00,
+	// NL80211_ATTR_STA_INFO
+	0x28, 0x00, 0x15, 0x00,
+	// NL80211_STA_INFO_SIGNAL -40
+	0x05, 0x00, 0x07, 0x00, 0xd8, 0x00, 0x00, 0x00,
+	// NL80211_STA_INFO_TX_BITRATE
+	0x1c, 0x00, 0x08, 0x00,
+	// NL80211_RATE_INFO_BITRATE 4333
+	0x06, 0x00, 0x01, 0x00, 0xed, 0x10, 0x00, 0x00,
+	// NL80211_RATE_INFO_BITRATE32 4333
+	0x08, 0x00, 0x05, 0x00, 0xed, 0x10, 0x00, 0x00,
+	// NL80211_RATE_INFO_MCS 9
+	0x05, 0x00, 0x02, 0x00, 0x09, 0x00, 0x00, 0x00,
+}
+
+func TestParseInterface(t *testing.T) {
+	skipBigEndian(t)
+
+	link := &Link{}
+	if err := parseInterface([][]byte{interfaceReply}, link); err != nil {
+		t.Fatal(err)
+	}
+	if link.SSID != "HomeNet" || link.Frequency != 5180 || link.Band() != "5GHz" {
+		t.Errorf("got %+v", link)
+	}
+}
+
+func TestParseStation(t *testing.T) {
+	skipBigEndian(t)
+
+	link := &Link{}
+	found, err := parseStation([][]byte{stationReply}, link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found || link.Signal != -40 || link.TxBitrate != 433.3e6 {
+		t.Errorf("got %v %+v", found, link)
+	}
+}
+
+func TestParseStationNone(t *testing.T) {
+	found, err := parseStation(nil, &Link{})
+	if err != nil || found {
+		t.Errorf("no replies should find no station, got %v %v", found, err)
+	}
+}
+
+func TestParseBitrate(t *testing.T) {
+	skipBigEndian(t)
+
+	tests := []struct {
+		name  string
+		attrs []byte
+		want  float64
+	}{
+		{
+			// Only the 16 bit rate, as old kernels send
+			name:  "16 bit",
+			attrs: []byte{0x06, 0x00, 0x01, 0x00, 0x14, 0x05, 0x00, 0x00},
+			want:  130e6,
+		},
+		{
+			// Too fast for 16 bits, the 16 bit rate is left out
+			name:  "32 bit",
+			attrs: []byte{0x08, 0x00, 0x05, 0x00, 0x40, 0x9c, 0x01, 0x00},
+			want:  10553.6e6,
+		},
+		{
+			// The 32 bit rate wins whichever comes first
+			name: "32 bit after 16 bit",
+			attrs: []byte{
+				0x06, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00,
+				0x08, 0x00, 0x05, 0x00, 0xed, 0x10, 0x00, 0x00,
+			},
+			want: 433.3e6,
+		},
+	}
+
+	for _, tt := range tests {
+		rate, err := parseBitrate(netlink.Attr{Type: 8, Data: tt.attrs})
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if rate != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, rate, tt.want)
+		}
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	// Cut off in the middle of the SSID
+	short := interfaceReply[:len(interfaceReply)-6]
+	if err := parseInterface([][]byte{short}, &Link{}); err == nil {
+		t.Error("truncated interface reply: expected an error")
+	}
+
+	// STA_INFO claims more than it holds
+	bad := append([]byte{}, stationReply...)
+	bad[24] = 0x30
+	if _, err := parseStation([][]byte{bad}, &Link{}); err == nil {
+		t.Error("overlong STA_INFO: expected an error")
+	}
+
+	// A nested attribute with a length below its header
+	if _, err := parseBitrate(netlink.Attr{Data: []byte{0x02, 0x00, 0x01, 0x00}}); err == nil {
+		t.Error("bad bitrate length: expected an error")
+	}
+}
+
+const procWireless = `Inter-| sta-|   Quality        |   Discarded packets               | Missed | WE
+ face | tus | link level noise |  nwid  crypt   frag  retry   misc | beacon | 22
+wlan0: 0000   52.  -58.  -256        0      0      0      0      0        0
+wlan1: 0000   80.  -20.  -256        0      0      0      0      0        0
+`
+
+func TestParseWireless(t *testing.T) {
+	quality, signal, err := parseWireless(strings.NewReader(procWireless), "wlan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if quality < 74.28 || quality > 74.29 || signal != -58 {
+		t.Errorf("got %v %v", quality, signal)
+	}
+
+	// Quality is capped at 100%
+	quality, _, err = parseWireless(strings.NewReader(procWireless), "wlan1")
+	if err != nil || quality != 100 {
+		t.Errorf("got %v %v", quality, err)
+	}
+
+	if _, _, err := parseWireless(strings.NewReader(procWireless), "wlan2"); err == nil {
+		t.Error("missing device: expected an error")
+	}
+}
+
+const iwConnected = `Connected to 00:11:22:33:44:55 (on wlan0)
+	SSID: Home: Net
+	freq: 2437
+	RX: 123456 bytes (789 packets)
+	TX: 23456 bytes (123 packets)
+	signal: -61 dBm
+	rx bitrate: 65.0 MBit/s MCS 7
+	tx bitrate: 72.2 MBit/s MCS 7 short GI
+
+	bss flags:	short-preamble short-slot-time
+	dtim period:	1
+	beacon int:	100
+`
+
+func TestParseIw(t *testing.T) {
+	link, err := parseIw(strings.NewReader(iwConnected), "wlan0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.SSID != "Home: Net" || link.Frequency != 2437 || link.Signal != -61 ||
+		link.TxBitrate != 72.2e6 || link.Band() != "2.4GHz" {
+		t.Errorf("got %+v", link)
+	}
+
+	_, err = parseIw(strings.NewReader("Not connected.\n"), "wlan0")
+	if err != ErrNotConnected {
+		t.Errorf("got %v, want ErrNotConnected", err)
+	}
+}
+
+func TestSignalQuality(t *testing.T) {
+	for signal, want := range map[int]float64{-110: 0, -100: 0, -75: 50, -50: 100, -30: 100} {
+		if got := signalQuality(signal); got != want {
+			t.Errorf("%d dBm: got %v, want %v", signal, got, want)
+		}
+	}
+}
@@ -2,11 +2,15 @@
 protocol is the specification of the i3bar protocol.
 The Block type is what we send to i3bar.
 The Click type is what we receive from i3bar.
+Block.MinWidth is a number of pixels, Block.MinWidthText is sent
+in its place when set and i3bar uses the width of the text.
 
 See: https://i3wm.org/docs/i3bar-protocol.html
 */
 package protocol
 
+import "encoding/json"
+
 type (
 	Block struct {
 		FullText   string `json:"full_text"`
@@ -21,6 +25,9 @@ type (
 		Urgent     bool   `json:"urgent,omitempty"`
 		Separator  bool   `json:"separator,omitempty"`
 		Markup     string `json:"markup,omitempty"`
+
+		// MinWidthText replaces MinWidth in the JSON when set
+		MinWidthText string `json:"-"`
 	}
 
 	Click struct {
@@ -36,3 +43,18 @@ type (
 		Height    int      `json:"height,omitempty"`
 	}
 )
+
+// MarshalJSON sends MinWidthText as min_width when it is set.
+func (b Block) MarshalJSON() ([]byte, error) {
+	// block has no methods, so json.Marshal doesn't recurse
+	type block Block
+	if b.MinWidthText == "" {
+		return json.Marshal(block(b))
+	}
+
+	// The shallower MinWidth hides the one in block
+	return json.Marshal(struct {
+		block
+		MinWidth string `json:"min_width"`
+	}{block(b), b.MinWidthText})
+}
@@ -0,0 +1,31 @@
+package protocol
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalMinWidth(t *testing.T) {
+	tests := []struct {
+		block Block
+		want  string
+	}{
+		{Block{FullText: "cpu"}, `{"full_text":"cpu"}`},
+		{Block{FullText: "cpu", MinWidth: 5}, `{"full_text":"cpu","min_width":5}`},
+		{
+			Block{FullText: "cpu", MinWidth: 5, MinWidthText: "cpu 100%"},
+			`{"full_text":"cpu","min_width":"cpu 100%"}`,
+		},
+	}
+
+	for _, test := range tests {
+		// clarkio marshals pointers
+		got, err := json.Marshal(&test.block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}